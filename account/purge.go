@@ -0,0 +1,122 @@
+// Package account removes the data of deleted accounts once their grace
+// period is over.
+package account
+
+import (
+	"auth-service/audit"
+	jwt "auth-service/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// purgeBatchSize bounds how many accounts one sweep purges.
+const purgeBatchSize = 100
+
+// PurgeDeletedAccounts permanently removes accounts that were soft-deleted
+// and whose purge_after is before now. Each account is purged in its own
+// transaction, so several instances can sweep at once.
+func PurgeDeletedAccounts(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`SELECT id FROM users
+		WHERE deleted_at IS NOT NULL AND purge_after <= $1
+		ORDER BY purge_after LIMIT $2`, now, purgeBatchSize)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		ok, err := purge(db, id, now)
+		if err != nil {
+			return fmt.Errorf("purging user %d: %w", id, err)
+		}
+		if ok {
+			purged++
+		}
+	}
+	if purged > 0 {
+		log.Printf("Purged %d deleted accounts", purged)
+	}
+	return nil
+}
+
+// purge deletes everything keyed by the user's id or username. Audit events
+// about the user go too; events the user caused elsewhere are kept with the
+// actor replaced. It reports false without doing anything when the account
+// is no longer due or another sweeper holds it.
+func purge(db *sql.DB, userID int, now time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var username string
+	err = tx.QueryRow(`SELECT username FROM users
+		WHERE id = $1 AND deleted_at IS NOT NULL AND purge_after <= $2
+		FOR UPDATE SKIP LOCKED`, userID, now).Scan(&username)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	statements := []string{
+		"DELETE FROM refresh_tokens WHERE username = $1",
+		"DELETE FROM reference_tokens WHERE username = $1",
+		`DELETE FROM relation_tuples WHERE (subject_type = 'user' AND subject_id = $1)
+			OR (object_type = 'user' AND object_id = $1)`,
+	}
+	// An account registered before reserved names were refused would match
+	// events written by the service itself; those are left alone.
+	if !audit.ReservedName(username) {
+		statements = append(statements, "DELETE FROM audit_events WHERE subject = $1")
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, username); err != nil {
+			return false, err
+		}
+	}
+	if !audit.ReservedName(username) {
+		if _, err := tx.Exec("UPDATE audit_events SET actor = $1 WHERE actor = $2", audit.ActorDeleted, username); err != nil {
+			return false, err
+		}
+	}
+	// Role grants, group memberships and outstanding mail tokens cascade.
+	if _, err := tx.Exec("DELETE FROM users WHERE id = $1", userID); err != nil {
+		return false, err
+	}
+	if err := audit.Record(tx, audit.Event{
+		Type:    audit.AccountPurged,
+		Actor:   audit.ActorSystem,
+		Subject: fmt.Sprintf("user:%d", userID),
+	}); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartPurger purges deleted accounts every interval.
+func StartPurger(db *sql.DB, interval time.Duration) (stop func()) {
+	return jwt.StartSweeper("deleted accounts", interval, func(now time.Time) error {
+		return PurgeDeletedAccounts(db, now)
+	})
+}
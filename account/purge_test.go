@@ -0,0 +1,112 @@
+package account_test
+
+import (
+	"auth-service/account"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id FROM users").
+		WithArgs(now, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM users .* FOR UPDATE SKIP LOCKED").
+		WithArgs(7, now).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice"))
+	for _, stmt := range []string{
+		"DELETE FROM refresh_tokens",
+		"DELETE FROM reference_tokens",
+		"DELETE FROM relation_tuples",
+		"DELETE FROM audit_events",
+	} {
+		mock.ExpectExec(stmt).WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("UPDATE audit_events SET actor = \\$1 WHERE actor = \\$2").
+		WithArgs("deleted-user", "alice").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM users WHERE id = \\$1").
+		WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("account.purged", "system", "user:7", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, account.PurgeDeletedAccounts(mockDB, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeDeletedAccounts_Nothing(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id FROM users").
+		WithArgs(now, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	require.NoError(t, account.PurgeDeletedAccounts(mockDB, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// An account another sweeper already holds, or that was purged since the
+// batch was read, is skipped without an audit record.
+func TestPurgeDeletedAccounts_AlreadyTaken(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id FROM users").
+		WithArgs(now, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM users .* FOR UPDATE SKIP LOCKED").
+		WithArgs(7, now).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}))
+	mock.ExpectRollback()
+
+	require.NoError(t, account.PurgeDeletedAccounts(mockDB, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// A legacy account with a reserved name leaves the service's own events alone.
+func TestPurgeDeletedAccounts_ReservedName(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id FROM users").
+		WithArgs(now, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM users .* FOR UPDATE SKIP LOCKED").
+		WithArgs(8, now).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("system"))
+	for _, stmt := range []string{
+		"DELETE FROM refresh_tokens",
+		"DELETE FROM reference_tokens",
+		"DELETE FROM relation_tuples",
+	} {
+		mock.ExpectExec(stmt).WithArgs("system").WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("DELETE FROM users WHERE id = \\$1").
+		WithArgs(8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("account.purged", "system", "user:8", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, account.PurgeDeletedAccounts(mockDB, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
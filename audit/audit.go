@@ -0,0 +1,67 @@
+// Package audit records security-relevant events in the audit_events table.
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+// Event types.
+const (
+	RoleGrantExpired       = "role_grant.expired"
+	PasswordResetRequested = "password.reset_requested"
+	PasswordReset          = "password.reset"
+	PasswordChanged        = "password.changed"
+	LoginSucceeded         = "login.succeeded"
+	LoginFailed            = "login.failed"
+	AccountExported        = "account.exported"
+	AccountDeleted         = "account.deleted"
+	AccountPurged          = "account.purged"
+	EmailVerified          = "email.verified"
+)
+
+// ActorSystem is the actor of events caused by the service itself, such as
+// background sweepers.
+const ActorSystem = "system"
+
+// ActorDeleted replaces the actor of events left behind by a purged account.
+const ActorDeleted = "deleted-user"
+
+// ReservedName reports whether name could be mistaken for an actor or subject
+// the service writes itself: ActorSystem, ActorDeleted, or a typed subject
+// such as "user:<id>" or "group:<name>". Such names cannot be usernames, or
+// their owner would see, and on purge rewrite, events that are not theirs.
+func ReservedName(name string) bool {
+	return name == ActorSystem || name == ActorDeleted || strings.Contains(name, ":")
+}
+
+// Event is one audit record. Subject identifies what the event is about,
+// e.g. a username or "group:<name>".
+type Event struct {
+	Type    string
+	Actor   string
+	Subject string
+	Details map[string]interface{}
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so events can be written
+// in the same transaction as the change they describe.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Record writes e.
+func Record(q execer, e Event) error {
+	details := e.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec("INSERT INTO audit_events (event_type, actor, subject, details) VALUES ($1, $2, $3, $4)",
+		e.Type, e.Actor, e.Subject, raw)
+	return err
+}
@@ -0,0 +1,54 @@
+package authz_test
+
+import (
+	"auth-service/authz"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeExpiredGrants(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM user_roles ur USING users u, roles r").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "name", "expires_at"}).AddRow("contractor", "recruiter", expired))
+	mock.ExpectQuery("DELETE FROM group_roles gr USING groups g, roles r").
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "name", "expires_at"}).AddRow("temps", "employer", expired))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("role_grant.expired", "system", "contractor", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("role_grant.expired", "system", "group:temps", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, authz.PurgeExpiredGrants(mockDB, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeExpiredGrants_Nothing(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM user_roles").WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "name", "expires_at"}))
+	mock.ExpectQuery("DELETE FROM group_roles").WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "name", "expires_at"}))
+	mock.ExpectCommit()
+
+	require.NoError(t, authz.PurgeExpiredGrants(mockDB, now))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
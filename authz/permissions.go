@@ -0,0 +1,125 @@
+// Package authz decides what a user may do. A user's effective permissions
+// are those granted to the roles they hold, either directly or through the
+// groups they belong to, and to the ancestors of those roles. All permission
+// checks go through a Resolver, which caches each user's permissions in
+// memory.
+package authz
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wildcard matches any resource, instance or action in a granted permission.
+const Wildcard = "*"
+
+// Permission is an action on a resource, optionally narrowed to a single
+// instance of it: ("job", "", "publish") or ("job", "1234", "edit"). In a
+// grant any part may be Wildcard, and an empty Instance covers every
+// instance.
+type Permission struct {
+	Resource string `json:"resource"`
+	Instance string `json:"instance,omitempty"`
+	Action   string `json:"action"`
+}
+
+// String renders the permission as resource:action or
+// resource:instance:action.
+func (p Permission) String() string {
+	if p.Instance != "" {
+		return p.Resource + ":" + p.Instance + ":" + p.Action
+	}
+	return p.Resource + ":" + p.Action
+}
+
+// ParsePermission parses the String form of a permission. "job:*" grants
+// every action on every job.
+func ParsePermission(s string) (Permission, error) {
+	parts := strings.Split(s, ":")
+	for _, part := range parts {
+		if part == "" {
+			return Permission{}, fmt.Errorf("invalid permission %q", s)
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return Permission{Resource: parts[0], Action: parts[1]}, nil
+	case 3:
+		return Permission{Resource: parts[0], Instance: parts[1], Action: parts[2]}, nil
+	default:
+		return Permission{}, fmt.Errorf("invalid permission %q", s)
+	}
+}
+
+// Grant records one way a user holds a permission: through Role, which is
+// assigned to the user directly or, when Group is set, through that group.
+// InheritedFrom names the ancestor of Role the permission was granted to,
+// when it was not granted to Role itself.
+type Grant struct {
+	Permission
+	Role          string `json:"role"`
+	Group         string `json:"group,omitempty"`
+	InheritedFrom string `json:"inheritedFrom,omitempty"`
+}
+
+// Permissions is a user's effective permission set.
+type Permissions struct {
+	grants []Grant
+	index  map[Permission]int
+}
+
+// NewPermissions builds a permission set from grants. When a permission is
+// granted more than once, the first grant is the one reported by Match.
+func NewPermissions(grants []Grant) *Permissions {
+	p := &Permissions{grants: grants, index: make(map[Permission]int, len(grants))}
+	for i, g := range grants {
+		if _, ok := p.index[g.Permission]; !ok {
+			p.index[g.Permission] = i
+		}
+	}
+	return p
+}
+
+// Match returns the grant that allows want, if any. A grant for a whole
+// resource covers each of its instances, and wildcards cover anything.
+// Rather than testing every grant, Match looks up each grant pattern that
+// could cover want, so its cost does not depend on the number of grants.
+// Narrower patterns are tried first, so the most specific grant is reported.
+func (p *Permissions) Match(want Permission) (Grant, bool) {
+	instances := []string{""}
+	if want.Instance != "" {
+		instances = []string{want.Instance, Wildcard, ""}
+	}
+	for _, resource := range []string{want.Resource, Wildcard} {
+		for _, instance := range instances {
+			for _, action := range []string{want.Action, Wildcard} {
+				if i, ok := p.index[Permission{Resource: resource, Instance: instance, Action: action}]; ok {
+					return p.grants[i], true
+				}
+			}
+		}
+	}
+	return Grant{}, false
+}
+
+// Has reports whether want is allowed.
+func (p *Permissions) Has(want Permission) bool {
+	_, ok := p.Match(want)
+	return ok
+}
+
+// Grants returns every grant behind the permission set.
+func (p *Permissions) Grants() []Grant {
+	return append([]Grant(nil), p.grants...)
+}
+
+// List returns each distinct granted permission once, in grant order.
+func (p *Permissions) List() []Permission {
+	list := make([]Permission, 0, len(p.index))
+	for i, g := range p.grants {
+		if p.index[g.Permission] == i {
+			list = append(list, g.Permission)
+		}
+	}
+	return list
+}
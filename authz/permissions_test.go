@@ -0,0 +1,91 @@
+package authz_test
+
+import (
+	"auth-service/authz"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func grantOf(t *testing.T, pattern, role string) authz.Grant {
+	p, err := authz.ParsePermission(pattern)
+	require.NoError(t, err)
+	return authz.Grant{Permission: p, Role: role}
+}
+
+func TestParsePermission(t *testing.T) {
+	p, err := authz.ParsePermission("job:1234:edit")
+	require.NoError(t, err)
+	assert.Equal(t, authz.Permission{Resource: "job", Instance: "1234", Action: "edit"}, p)
+	assert.Equal(t, "job:1234:edit", p.String())
+
+	p, err = authz.ParsePermission("job:*")
+	require.NoError(t, err)
+	assert.Equal(t, authz.Permission{Resource: "job", Action: "*"}, p)
+
+	for _, bad := range []string{"job", "job::edit", "a:b:c:d", ""} {
+		_, err := authz.ParsePermission(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPermissions_Match(t *testing.T) {
+	permissions := authz.NewPermissions([]authz.Grant{
+		grantOf(t, "job:read", "user"),
+		grantOf(t, "job:1234:edit", "owner"),
+		grantOf(t, "job:*:delete", "moderator"),
+		grantOf(t, "report:*", "analyst"),
+	})
+
+	tests := []struct {
+		want    string
+		allowed bool
+		role    string
+	}{
+		{"job:read", true, "user"},
+		{"job:1234:read", true, "user"}, // a resource-wide grant covers instances
+		{"job:1234:edit", true, "owner"},
+		{"job:5678:edit", false, ""},
+		{"job:edit", false, ""}, // an instance grant does not cover the resource
+		{"job:5678:delete", true, "moderator"},
+		{"job:delete", false, ""},
+		{"report:export", true, "analyst"},
+		{"report:9:export", true, "analyst"},
+		{"user:read", false, ""},
+	}
+	for _, tt := range tests {
+		want, err := authz.ParsePermission(tt.want)
+		require.NoError(t, err)
+		grant, ok := permissions.Match(want)
+		assert.Equal(t, tt.allowed, ok, tt.want)
+		assert.Equal(t, tt.role, grant.Role, tt.want)
+	}
+}
+
+func TestPermissions_MatchPrefersSpecificGrant(t *testing.T) {
+	permissions := authz.NewPermissions([]authz.Grant{
+		grantOf(t, "*:*", "superuser"),
+		grantOf(t, "job:1234:edit", "owner"),
+	})
+	grant, ok := permissions.Match(authz.Permission{Resource: "job", Instance: "1234", Action: "edit"})
+	assert.True(t, ok)
+	assert.Equal(t, "owner", grant.Role)
+}
+
+func BenchmarkPermissions_Match(b *testing.B) {
+	grants := make([]authz.Grant, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		grants = append(grants, authz.Grant{
+			Permission: authz.Permission{Resource: "job", Instance: fmt.Sprint(i), Action: "edit"},
+			Role:       "owner",
+		})
+	}
+	permissions := authz.NewPermissions(grants)
+	want := authz.Permission{Resource: "job", Instance: "999999", Action: "edit"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		permissions.Match(want)
+	}
+}
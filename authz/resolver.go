@@ -0,0 +1,216 @@
+package authz
+
+import (
+	"auth-service/db"
+	jwt "auth-service/utils"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnknownUser is returned when a subject does not name an existing user.
+var ErrUnknownUser = errors.New("user does not exist")
+
+// Source loads the data permissions are resolved from.
+type Source interface {
+	// UserID maps a token subject (the username) to a user ID.
+	UserID(subject string) (int, error)
+	// Grants returns every permission grant that applies to the user.
+	Grants(userID int) ([]Grant, error)
+	// Revision changes whenever any grant may have changed.
+	Revision() (int64, error)
+}
+
+// Resolver computes effective permissions and caches them for TTL. Cached
+// entries are also dropped as soon as Refresh sees a new source revision or
+// Invalidate is called. A zero TTL disables caching. Grants that start or
+// end on a schedule take effect within one TTL.
+type Resolver struct {
+	Source Source
+	TTL    time.Duration
+
+	mu             sync.Mutex
+	entries        map[int]cachedPermissions
+	ids            map[string]int
+	revision       int64 // bumped on every local invalidation
+	sourceRevision int64 // last revision seen by Refresh
+}
+
+type cachedPermissions struct {
+	permissions *Permissions
+	fetchedAt   time.Time
+}
+
+// maxCachedUsers bounds the cache; when it fills up the whole cache is
+// dropped rather than tracking recency.
+const maxCachedUsers = 10000
+
+// NewResolver returns a resolver over source that caches for ttl.
+func NewResolver(source Source, ttl time.Duration) *Resolver {
+	return &Resolver{
+		Source:  source,
+		TTL:     ttl,
+		entries: make(map[int]cachedPermissions),
+		ids:     make(map[string]int),
+	}
+}
+
+// Permissions returns the effective permissions of the user with userID.
+func (r *Resolver) Permissions(userID int) (*Permissions, error) {
+	now := time.Now()
+	r.mu.Lock()
+	if entry, ok := r.entries[userID]; ok && now.Sub(entry.fetchedAt) < r.TTL {
+		r.mu.Unlock()
+		return entry.permissions, nil
+	}
+	revision := r.revision
+	r.mu.Unlock()
+
+	grants, err := r.Source.Grants(userID)
+	if err != nil {
+		return nil, err
+	}
+	permissions := NewPermissions(grants)
+	if r.TTL <= 0 {
+		return permissions, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Don't cache a result that may predate an invalidation that happened
+	// while it was being loaded.
+	if r.revision == revision {
+		if len(r.entries) >= maxCachedUsers {
+			r.entries = make(map[int]cachedPermissions)
+		}
+		r.entries[userID] = cachedPermissions{permissions: permissions, fetchedAt: now}
+	}
+	return permissions, nil
+}
+
+// SubjectPermissions is Permissions for the user a token subject names.
+func (r *Resolver) SubjectPermissions(subject string) (*Permissions, error) {
+	userID, err := r.userID(subject)
+	if err != nil {
+		return nil, err
+	}
+	return r.Permissions(userID)
+}
+
+// userID resolves a subject, caching the answer; user IDs never change.
+func (r *Resolver) userID(subject string) (int, error) {
+	r.mu.Lock()
+	id, ok := r.ids[subject]
+	r.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	id, err := r.Source.UserID(subject)
+	if err != nil {
+		return 0, err
+	}
+	if r.TTL > 0 {
+		r.mu.Lock()
+		if len(r.ids) >= maxCachedUsers {
+			r.ids = make(map[string]int)
+		}
+		r.ids[subject] = id
+		r.mu.Unlock()
+	}
+	return id, nil
+}
+
+// Check reports whether the user is allowed want, and which grant allowed
+// it.
+func (r *Resolver) Check(userID int, want Permission) (Grant, bool, error) {
+	permissions, err := r.Permissions(userID)
+	if err != nil {
+		return Grant{}, false, err
+	}
+	grant, ok := permissions.Match(want)
+	return grant, ok, nil
+}
+
+// CheckSubject is Check for the user a token subject names.
+func (r *Resolver) CheckSubject(subject string, want Permission) (Grant, bool, error) {
+	permissions, err := r.SubjectPermissions(subject)
+	if err != nil {
+		return Grant{}, false, err
+	}
+	grant, ok := permissions.Match(want)
+	return grant, ok, nil
+}
+
+// Invalidate drops the cached permissions of one user.
+func (r *Resolver) Invalidate(userID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, userID)
+	r.revision++
+}
+
+// InvalidateAll drops every cached permission set. Subject to user ID
+// mappings are dropped too, since a deleted username may be reused.
+func (r *Resolver) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make(map[int]cachedPermissions)
+	r.ids = make(map[string]int)
+	r.revision++
+}
+
+// Refresh checks the source revision and drops the cache if it has moved.
+func (r *Resolver) Refresh() error {
+	revision, err := r.Source.Revision()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	seen := r.sourceRevision
+	r.sourceRevision = revision
+	r.mu.Unlock()
+	if revision != seen {
+		r.InvalidateAll()
+	}
+	return nil
+}
+
+// StartInvalidationWatcher calls Refresh every interval, so changes made by
+// other instances or by hand in the database reach this cache within one
+// interval.
+func StartInvalidationWatcher(r *Resolver, interval time.Duration) (stop func()) {
+	return jwt.StartSweeper("authz cache", interval, func(time.Time) error {
+		return r.Refresh()
+	})
+}
+
+var (
+	resolverMu sync.RWMutex
+	resolver   *Resolver
+)
+
+// SetResolver installs the resolver used by Default. Without one, every call
+// resolves straight from db.DB with no caching.
+func SetResolver(r *Resolver) {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+	resolver = r
+}
+
+// Default returns the installed resolver.
+func Default() *Resolver {
+	resolverMu.RLock()
+	r := resolver
+	resolverMu.RUnlock()
+	if r != nil {
+		return r
+	}
+	return NewResolver(NewSQLSource(db.DB), 0)
+}
+
+// InvalidateAll drops the default resolver's cache. Handlers that change
+// grants call it so that their own instance sees the change immediately.
+func InvalidateAll() {
+	Default().InvalidateAll()
+}
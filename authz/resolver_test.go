@@ -0,0 +1,113 @@
+package authz_test
+
+import (
+	"auth-service/authz"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource serves fixed grants and counts how often they are loaded.
+type fakeSource struct {
+	grants   map[int][]authz.Grant
+	ids      map[string]int
+	revision int64
+	loads    int
+}
+
+func (s *fakeSource) UserID(subject string) (int, error) {
+	id, ok := s.ids[subject]
+	if !ok {
+		return 0, authz.ErrUnknownUser
+	}
+	return id, nil
+}
+
+func (s *fakeSource) Grants(userID int) ([]authz.Grant, error) {
+	s.loads++
+	return s.grants[userID], nil
+}
+
+func (s *fakeSource) Revision() (int64, error) {
+	return s.revision, nil
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		ids: map[string]int{"alice": 1},
+		grants: map[int][]authz.Grant{1: {
+			{Permission: authz.Permission{Resource: "job", Action: "publish"}, Role: "employer"},
+			{Permission: authz.Permission{Resource: "job", Action: "publish"}, Role: "recruiter", Group: "acme"},
+			{Permission: authz.Permission{Resource: "job", Action: "view"}, Role: "recruiter", Group: "acme"},
+		}},
+	}
+}
+
+func TestResolver_Check(t *testing.T) {
+	r := authz.NewResolver(newFakeSource(), time.Minute)
+
+	grant, ok, err := r.CheckSubject("alice", authz.Permission{Resource: "job", Action: "publish"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "employer", grant.Role, "the first grant wins")
+
+	grant, ok, err = r.Check(1, authz.Permission{Resource: "job", Action: "view"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", grant.Group)
+
+	_, ok, err = r.Check(1, authz.Permission{Resource: "job", Action: "delete"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = r.CheckSubject("mallory", authz.Permission{Resource: "job", Action: "view"})
+	assert.ErrorIs(t, err, authz.ErrUnknownUser)
+}
+
+func TestResolver_List(t *testing.T) {
+	r := authz.NewResolver(newFakeSource(), 0)
+	permissions, err := r.Permissions(1)
+	require.NoError(t, err)
+	assert.Equal(t, []authz.Permission{{Resource: "job", Action: "publish"}, {Resource: "job", Action: "view"}},
+		permissions.List())
+	assert.Len(t, permissions.Grants(), 3)
+}
+
+func TestResolver_Caching(t *testing.T) {
+	source := newFakeSource()
+	r := authz.NewResolver(source, time.Minute)
+
+	_, err := r.Permissions(1)
+	require.NoError(t, err)
+	_, err = r.Permissions(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, source.loads)
+
+	r.Invalidate(1)
+	_, err = r.Permissions(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, source.loads)
+
+	// A moved source revision drops the cache; an unchanged one does not.
+	require.NoError(t, r.Refresh())
+	_, _ = r.Permissions(1)
+	loads := source.loads
+	require.NoError(t, r.Refresh())
+	_, _ = r.Permissions(1)
+	assert.Equal(t, loads, source.loads)
+
+	source.revision++
+	require.NoError(t, r.Refresh())
+	_, _ = r.Permissions(1)
+	assert.Equal(t, loads+1, source.loads)
+}
+
+func TestResolver_NoCaching(t *testing.T) {
+	source := newFakeSource()
+	r := authz.NewResolver(source, 0)
+	_, _ = r.Permissions(1)
+	_, _ = r.Permissions(1)
+	assert.Equal(t, 2, source.loads)
+}
@@ -0,0 +1,118 @@
+package authz
+
+import (
+	"database/sql"
+)
+
+// SQLSource resolves grants from the user_roles, user_groups, group_roles and
+// role_permissions tables and the role hierarchy.
+type SQLSource struct {
+	DB *sql.DB
+}
+
+// NewSQLSource returns a source backed by db.
+func NewSQLSource(db *sql.DB) *SQLSource {
+	return &SQLSource{DB: db}
+}
+
+func (s *SQLSource) UserID(subject string) (int, error) {
+	var id int
+	err := s.DB.QueryRow("SELECT id FROM users WHERE username = $1 AND deleted_at IS NULL", subject).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrUnknownUser
+	}
+	return id, err
+}
+
+// MaxRoleDepth bounds how far up the role hierarchy permissions are
+// inherited from, so that a cycle written directly to the database cannot
+// make resolution loop.
+const MaxRoleDepth = 32
+
+// effectiveRoles defines chain(held_id, source_id, via, depth): one row for
+// each role a user holds, directly or through the group named by via, and
+// for each of its ancestors. $1 is the user id and $2 is MaxRoleDepth.
+const effectiveRoles = `WITH RECURSIVE held AS (
+			SELECT ur.role_id, '' AS via FROM user_roles ur
+			WHERE ur.user_id = $1
+				AND (ur.valid_from IS NULL OR ur.valid_from <= NOW())
+				AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+			UNION
+			SELECT gr.role_id, g.name FROM user_groups ug
+			JOIN groups g ON g.id = ug.group_id
+			JOIN group_roles gr ON gr.group_id = g.id
+			WHERE ug.user_id = $1
+				AND (gr.valid_from IS NULL OR gr.valid_from <= NOW())
+				AND (gr.expires_at IS NULL OR gr.expires_at > NOW())
+		), chain AS (
+			SELECT role_id AS held_id, role_id AS source_id, via, 0 AS depth FROM held
+			UNION
+			SELECT c.held_id, r.parent_id, c.via, c.depth + 1 FROM chain c
+			JOIN roles r ON r.id = c.source_id
+			WHERE r.parent_id IS NOT NULL AND c.depth < $2
+		)`
+
+// Queryer is satisfied by both *sql.DB and *sql.Tx.
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// UserRoles returns a user's effective roles from the same hierarchy walk
+// Grants uses, so token roles and permission decisions agree. Held roles
+// come before inherited ones, oldest first.
+func UserRoles(q Queryer, userID int) ([]string, error) {
+	rows, err := q.Query(effectiveRoles+`
+		SELECT r.name FROM roles r JOIN chain c ON c.source_id = r.id
+		GROUP BY r.id, r.name
+		ORDER BY MIN(c.depth), r.id`, userID, MaxRoleDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// Grants walks from each role the user holds, directly or through a group,
+// up through its ancestors. Grants outside their validity window are
+// ignored. For each permission, direct grants come before
+// group grants and nearer roles before more distant ancestors, so the most
+// specific grant is the one reported.
+func (s *SQLSource) Grants(userID int) ([]Grant, error) {
+	rows, err := s.DB.Query(effectiveRoles+`
+		SELECT p.resource, p.instance, p.action, h.name, c.via, CASE WHEN c.depth > 0 THEN src.name ELSE '' END
+		FROM chain c
+		JOIN roles h ON h.id = c.held_id
+		JOIN roles src ON src.id = c.source_id
+		JOIN role_permissions rp ON rp.role_id = c.source_id
+		JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY p.resource, p.instance, p.action, c.via <> '', c.depth, h.name, c.via`, userID, MaxRoleDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []Grant{}
+	for rows.Next() {
+		var g Grant
+		if err := rows.Scan(&g.Resource, &g.Instance, &g.Action, &g.Role, &g.Group, &g.InheritedFrom); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *SQLSource) Revision() (int64, error) {
+	var revision int64
+	err := s.DB.QueryRow("SELECT revision FROM authz_revision").Scan(&revision)
+	return revision, err
+}
@@ -0,0 +1,65 @@
+package authz_test
+
+import (
+	"auth-service/authz"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLSource(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+	source := authz.NewSQLSource(mockDB)
+
+	mock.ExpectQuery(`SELECT id FROM users WHERE username = \$1`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT p.resource, p.instance, p.action").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"resource", "instance", "action", "role", "via", "inherited_from"}).
+			AddRow("job", "", "publish", "employer", "", "").
+			AddRow("job", "", "view", "recruiter", "acme", "employer"))
+	mock.ExpectQuery("SELECT revision FROM authz_revision").
+		WillReturnRows(sqlmock.NewRows([]string{"revision"}).AddRow(7))
+	mock.ExpectQuery(`SELECT id FROM users WHERE username = \$1`).
+		WithArgs("mallory").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	id, err := source.UserID("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	grants, err := source.Grants(1)
+	require.NoError(t, err)
+	assert.Equal(t, []authz.Grant{
+		{Permission: authz.Permission{Resource: "job", Action: "publish"}, Role: "employer"},
+		{Permission: authz.Permission{Resource: "job", Action: "view"}, Role: "recruiter", Group: "acme", InheritedFrom: "employer"},
+	}, grants)
+
+	revision, err := source.Revision()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), revision)
+
+	_, err = source.UserID("mallory")
+	assert.ErrorIs(t, err, authz.ErrUnknownUser)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRoles(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery(`WITH RECURSIVE held AS \(.*\) SELECT r.name FROM roles r JOIN chain c ON c.source_id = r.id`).
+		WithArgs(1, authz.MaxRoleDepth).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("recruiter").AddRow("employer"))
+
+	roles, err := authz.UserRoles(mockDB, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"recruiter", "employer"}, roles)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
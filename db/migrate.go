@@ -0,0 +1,71 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrate applies every embedded SQL migration that has not been recorded in
+// the schema_migrations table yet. Migrations run in file-name order, each in
+// its own transaction.
+func Migrate() error {
+	if _, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(name, ".sql")
+
+		var applied bool
+		if err := DB.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied); err != nil {
+			return fmt.Errorf("checking migration %s: %w", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := DB.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(body)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("applying migration %s: %w", version, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("recording migration %s: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %s", version)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+-- Opaque refresh tokens. Only the SHA-256 hash of each token is stored.
+-- Tokens issued from the same login share a family_id so that reuse of a
+-- rotated token can revoke the whole chain.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         SERIAL PRIMARY KEY,
+    username   TEXT NOT NULL,
+    family_id  TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    parent_id  INTEGER REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_username_idx ON refresh_tokens (username);
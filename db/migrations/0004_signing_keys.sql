@@ -0,0 +1,9 @@
+-- Signing keys shared by all instances when scheduled rotation is enabled.
+-- material holds the AES-GCM encrypted private key (or HS256 secret).
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid        TEXT PRIMARY KEY,
+    alg        TEXT NOT NULL,
+    material   BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ
+);
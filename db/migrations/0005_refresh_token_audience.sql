@@ -0,0 +1,3 @@
+-- Remember which audience a refresh token family was issued for, so that
+-- rotation keeps minting access tokens for the same audience.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS audience TEXT NOT NULL DEFAULT '';
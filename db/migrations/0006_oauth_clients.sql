@@ -0,0 +1,8 @@
+-- Confidential clients (API gateway, other services) that authenticate with
+-- client credentials. Only the bcrypt hash of each secret is stored.
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    client_id   TEXT PRIMARY KEY,
+    secret_hash TEXT NOT NULL,
+    name        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Bumping a user's token_version invalidates every access token minted with
+-- an older version ("sign out everywhere").
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
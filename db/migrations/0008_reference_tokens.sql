@@ -0,0 +1,12 @@
+-- Server-side records behind opaque reference access tokens. The token
+-- itself is never stored, only its SHA-256 hash.
+CREATE TABLE IF NOT EXISTS reference_tokens (
+    token_hash TEXT PRIMARY KEY,
+    username   TEXT NOT NULL,
+    claims     JSONB NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS reference_tokens_expires_at_idx ON reference_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS reference_tokens_username_idx ON reference_tokens (username);
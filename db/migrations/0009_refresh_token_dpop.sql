@@ -0,0 +1,3 @@
+-- Thumbprint of the DPoP key a refresh token family is bound to, if any.
+-- Rotation then requires a proof signed with the same key.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS dpop_jkt TEXT NOT NULL DEFAULT '';
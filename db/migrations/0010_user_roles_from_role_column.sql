@@ -0,0 +1,19 @@
+-- Make roles/user_roles the source of truth for user roles. Every distinct
+-- users.role value becomes a roles row and each user gets the matching
+-- user_roles row. The users.role column is no longer read or written and is
+-- only kept so this migration can be rolled back.
+INSERT INTO roles (name, description) VALUES
+    ('jobseeker', 'Job seeker'),
+    ('employer', 'Employer'),
+    ('admin', 'Administrator')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users WHERE role IS NOT NULL AND role <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u JOIN roles r ON r.name = u.role
+ON CONFLICT DO NOTHING;
+
+COMMENT ON COLUMN users.role IS 'Deprecated: superseded by user_roles';
@@ -0,0 +1,31 @@
+-- authz_revision is bumped by any change to the tables permissions are
+-- resolved from, so every instance can tell when its cached permissions are
+-- stale by polling a single row.
+CREATE TABLE IF NOT EXISTS authz_revision (
+    id       BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    revision BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO authz_revision (id) VALUES (TRUE) ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION bump_authz_revision() RETURNS trigger AS $$
+BEGIN
+    UPDATE authz_revision SET revision = revision + 1;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER roles_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON roles
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
+CREATE TRIGGER permissions_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON permissions
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
+CREATE TRIGGER groups_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON groups
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
+CREATE TRIGGER user_roles_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON user_roles
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
+CREATE TRIGGER user_groups_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON user_groups
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
+CREATE TRIGGER group_roles_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON group_roles
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
+CREATE TRIGGER role_permissions_authz_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON role_permissions
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_authz_revision();
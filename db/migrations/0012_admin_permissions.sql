@@ -0,0 +1,16 @@
+-- Permissions guarding the admin API, granted to the admin role so that
+-- existing admins keep their access when routes switch from role checks to
+-- permission checks.
+INSERT INTO permissions (resource, action, description) VALUES
+    ('role', 'manage', 'Create, edit and delete roles and their permissions'),
+    ('permission', 'manage', 'Create, edit and delete permissions'),
+    ('group', 'manage', 'Create, edit and delete groups, their members and roles'),
+    ('session', 'revoke', 'Revoke every session of another user')
+ON CONFLICT (resource, action) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r
+JOIN permissions p ON (p.resource, p.action) IN (
+    ('role', 'manage'), ('permission', 'manage'), ('group', 'manage'), ('session', 'revoke'))
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+-- A role inherits every permission of its parent, and transitively of the
+-- parent's ancestors. Cycles are rejected by the admin API; the resolver also
+-- bounds its walk so a cycle introduced by hand cannot loop forever.
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES roles(id) ON DELETE SET NULL;
+ALTER TABLE roles ADD CONSTRAINT roles_parent_not_self CHECK (parent_id <> id);
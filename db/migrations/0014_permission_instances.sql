@@ -0,0 +1,6 @@
+-- Permissions may be narrowed to one instance of a resource, e.g. a single
+-- job listing. An empty instance covers every instance, and '*' in any of
+-- resource, instance or action matches anything.
+ALTER TABLE permissions ADD COLUMN IF NOT EXISTS instance TEXT NOT NULL DEFAULT '';
+ALTER TABLE permissions DROP CONSTRAINT IF EXISTS permissions_resource_action_key;
+ALTER TABLE permissions ADD CONSTRAINT permissions_resource_instance_action_key UNIQUE (resource, instance, action);
@@ -0,0 +1,23 @@
+-- Role grants may be limited to a time window. NULL bounds are open, so
+-- existing grants stay valid indefinitely.
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ;
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_window CHECK (valid_from < expires_at);
+CREATE INDEX IF NOT EXISTS user_roles_expires_at_idx ON user_roles (expires_at) WHERE expires_at IS NOT NULL;
+
+ALTER TABLE group_roles ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ;
+ALTER TABLE group_roles ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE group_roles ADD CONSTRAINT group_roles_window CHECK (valid_from < expires_at);
+CREATE INDEX IF NOT EXISTS group_roles_expires_at_idx ON group_roles (expires_at) WHERE expires_at IS NOT NULL;
+
+-- Security-relevant events, e.g. grants removed by the expiry sweeper.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    actor      TEXT NOT NULL DEFAULT '',
+    subject    TEXT NOT NULL DEFAULT '',
+    details    JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_subject_idx ON audit_events (subject, created_at);
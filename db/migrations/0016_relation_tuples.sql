@@ -0,0 +1,15 @@
+-- Relationship tuples: subject has relation to object. A subject with a
+-- subject_relation is a userset, e.g. every recruiter of company 7.
+CREATE TABLE IF NOT EXISTS relation_tuples (
+    object_type      TEXT NOT NULL,
+    object_id        TEXT NOT NULL,
+    relation         TEXT NOT NULL,
+    subject_type     TEXT NOT NULL,
+    subject_id       TEXT NOT NULL,
+    subject_relation TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (object_type, object_id, relation, subject_type, subject_id, subject_relation)
+);
+
+CREATE INDEX IF NOT EXISTS relation_tuples_subject_idx
+    ON relation_tuples (subject_type, subject_id, subject_relation);
@@ -0,0 +1,7 @@
+-- Soft-deleted accounts can no longer sign in and are removed for good by
+-- the purge sweeper once purge_after has passed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS purge_after TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS users_purge_after_idx ON users (purge_after) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor, created_at);
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"auth-service/audit"
+	"auth-service/authz"
+	"auth-service/db"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// accountPurgeGracePeriod is how long a deleted account is kept before it is
+// purged, read from ACCOUNT_PURGE_GRACE_PERIOD as a Go duration. It defaults
+// to 30 days.
+func accountPurgeGracePeriod() time.Duration {
+	if grace, err := time.ParseDuration(os.Getenv("ACCOUNT_PURGE_GRACE_PERIOD")); err == nil && grace >= 0 {
+		return grace
+	}
+	return 30 * 24 * time.Hour
+}
+
+type exportedRoleGrant struct {
+	Role      string     `json:"role"`
+	ValidFrom *time.Time `json:"validFrom,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// exportedSession is a refresh token, without the token itself.
+type exportedSession struct {
+	Audience  string     `json:"audience,omitempty"`
+	DPoPBound bool       `json:"dpopBound"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type exportedEvent struct {
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Subject   string          `json:"subject"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type exportedRelation struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+}
+
+// accountExport is the archive returned by GET /me/export.
+type accountExport struct {
+	ExportedAt   time.Time           `json:"exportedAt"`
+	Account      profile             `json:"account"`
+	RoleGrants   []exportedRoleGrant `json:"roleGrants"`
+	Sessions     []exportedSession   `json:"sessions"`
+	LoginHistory []exportedEvent     `json:"loginHistory"`
+	AuditEvents  []exportedEvent     `json:"auditEvents"`
+	Relations    []exportedRelation  `json:"relations"`
+}
+
+// buildExport gathers everything stored about a user.
+func buildExport(user profile) (*accountExport, error) {
+	export := &accountExport{
+		ExportedAt:   time.Now().UTC(),
+		Account:      user,
+		RoleGrants:   []exportedRoleGrant{},
+		Sessions:     []exportedSession{},
+		LoginHistory: []exportedEvent{},
+		AuditEvents:  []exportedEvent{},
+		Relations:    []exportedRelation{},
+	}
+
+	rows, err := db.DB.Query(`SELECT r.name, ur.valid_from, ur.expires_at FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = $1 ORDER BY r.name`, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var g exportedRoleGrant
+		if err := rows.Scan(&g.Role, &g.ValidFrom, &g.ExpiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.RoleGrants = append(export.RoleGrants, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.Query(`SELECT audience, dpop_jkt <> '', created_at, expires_at, rotated_at, revoked_at
+		FROM refresh_tokens WHERE username = $1 ORDER BY created_at`, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s exportedSession
+		if err := rows.Scan(&s.Audience, &s.DPoPBound, &s.CreatedAt, &s.ExpiresAt, &s.RotatedAt, &s.RevokedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.Sessions = append(export.Sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Accounts registered before reserved names were refused could match
+	// events written by the service itself, so they get none.
+	if !audit.ReservedName(user.Username) {
+		if err := exportEvents(export, user.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err = db.DB.Query(`SELECT object_type || ':' || object_id, relation FROM relation_tuples
+		WHERE subject_type = 'user' AND subject_id = $1 AND subject_relation = ''
+		ORDER BY object_type, object_id, relation`, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rel exportedRelation
+		if err := rows.Scan(&rel.Object, &rel.Relation); err != nil {
+			return nil, err
+		}
+		export.Relations = append(export.Relations, rel)
+	}
+	return export, rows.Err()
+}
+
+// exportEvents adds the audit events by or about username to export. Sign-in
+// attempts are audit events too, but are reported on their own.
+func exportEvents(export *accountExport, username string) error {
+	rows, err := db.DB.Query(`SELECT event_type, actor, subject, details, created_at FROM audit_events
+		WHERE subject = $1 OR actor = $1 ORDER BY created_at, id`, username)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e exportedEvent
+		var details []byte
+		if err := rows.Scan(&e.Type, &e.Actor, &e.Subject, &details, &e.CreatedAt); err != nil {
+			return err
+		}
+		e.Details = json.RawMessage(details)
+		if strings.HasPrefix(e.Type, "login.") {
+			export.LoginHistory = append(export.LoginHistory, e)
+		} else {
+			export.AuditEvents = append(export.AuditEvents, e)
+		}
+	}
+	return rows.Err()
+}
+
+// ExportMeHandler returns a JSON archive of the caller's personal data: the
+// account and profile, role grants, sessions, login history, audit events
+// and relationships.
+func ExportMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims := r.Context().Value("userClaims").(*jwt.Claims)
+
+	user, err := loadUser(db.DB, claims.Subject, false)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	p, err := loadProfile(user)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	export, err := buildExport(p)
+	if err != nil {
+		log.Printf("Error exporting account %s: %v", user.Username, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := audit.Record(db.DB, audit.Event{Type: audit.AccountExported, Actor: user.Username, Subject: user.Username}); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", `attachment; filename="account-export.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteMeHandler soft-deletes the caller's account after checking their
+// password. Every token is revoked and role grants and group memberships are
+// removed at once; the rest of the account's data is purged by the account
+// sweeper once the grace period has passed.
+func DeleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims := r.Context().Value("userClaims").(*jwt.Claims)
+
+	var req deleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	var userID int
+	var storedPassword string
+	err := db.DB.QueryRow("SELECT id, password FROM users WHERE username = $1 AND deleted_at IS NULL", claims.Subject).
+		Scan(&userID, &storedPassword)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(req.Password)); err != nil {
+		http.Error(w, "Password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now().UTC()
+	purgeAfter := now.Add(accountPurgeGracePeriod())
+	if err := deleteAccount(claims.Subject, userID, now, purgeAfter); err != nil {
+		log.Printf("Error deleting account %s: %v", claims.Subject, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	authz.InvalidateAll()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(JSONResponse{
+		"message":    "Account deleted; its data will be permanently removed after the grace period",
+		"purgeAfter": purgeAfter,
+	})
+}
+
+func deleteAccount(username string, userID int, now, purgeAfter time.Time) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET deleted_at = $1, purge_after = $2, pending_email = NULL WHERE id = $3",
+		now, purgeAfter, userID); err != nil {
+		return err
+	}
+	if err := revokeAllSessions(tx, username); err != nil {
+		return err
+	}
+	// Removing grants bumps authz_revision, so every instance drops the
+	// user's cached permissions.
+	statements := []string{
+		"DELETE FROM user_roles WHERE user_id = $1",
+		"DELETE FROM user_groups WHERE user_id = $1",
+		"DELETE FROM password_reset_tokens WHERE user_id = $1",
+		"DELETE FROM email_verification_tokens WHERE user_id = $1",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, userID); err != nil {
+			return err
+		}
+	}
+	// Relationships would otherwise keep granting access until the purge.
+	if _, err := tx.Exec(`DELETE FROM relation_tuples WHERE (subject_type = 'user' AND subject_id = $1)
+		OR (object_type = 'user' AND object_id = $1)`, username); err != nil {
+		return err
+	}
+	if err := audit.Record(tx, audit.Event{
+		Type:    audit.AccountDeleted,
+		Actor:   username,
+		Subject: username,
+		Details: map[string]interface{}{"purgeAfter": purgeAfter},
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportMeHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	expectUser(mock, false)
+	expectRolesAndGroups(mock)
+	mock.ExpectQuery("SELECT r.name, ur.valid_from, ur.expires_at FROM user_roles ur").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "valid_from", "expires_at"}).
+			AddRow("employer", nil, now.Add(time.Hour)))
+	mock.ExpectQuery("SELECT audience, dpop_jkt <> '', created_at, expires_at, rotated_at, revoked_at").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"audience", "dpop", "created_at", "expires_at", "rotated_at", "revoked_at"}).
+			AddRow("jobs", false, now, now.Add(time.Hour), nil, nil))
+	mock.ExpectQuery("SELECT event_type, actor, subject, details, created_at FROM audit_events").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"event_type", "actor", "subject", "details", "created_at"}).
+			AddRow("login.succeeded", "alice", "alice", []byte(`{"ip":"192.0.2.1"}`), now).
+			AddRow("password.changed", "alice", "alice", []byte(`{}`), now))
+	mock.ExpectQuery("SELECT object_type \\|\\| ':' \\|\\| object_id, relation FROM relation_tuples").
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"object", "relation"}).AddRow("company:7", "recruiter"))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("account.exported", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	rec := httptest.NewRecorder()
+	handlers.ExportMeHandler(rec, withClaims(httptest.NewRequest("GET", "/me/export", nil), userClaims("alice")))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var export struct {
+		Account struct {
+			Username string   `json:"username"`
+			Groups   []string `json:"groups"`
+		} `json:"account"`
+		RoleGrants   []map[string]interface{} `json:"roleGrants"`
+		Sessions     []map[string]interface{} `json:"sessions"`
+		LoginHistory []map[string]interface{} `json:"loginHistory"`
+		AuditEvents  []map[string]interface{} `json:"auditEvents"`
+		Relations    []map[string]interface{} `json:"relations"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &export))
+	assert.Equal(t, "alice", export.Account.Username)
+	assert.Equal(t, []string{"acme"}, export.Account.Groups)
+	require.Len(t, export.RoleGrants, 1)
+	require.Len(t, export.Sessions, 1)
+	assert.Equal(t, "jobs", export.Sessions[0]["audience"])
+	require.Len(t, export.LoginHistory, 1)
+	assert.Equal(t, "192.0.2.1", export.LoginHistory[0]["details"].(map[string]interface{})["ip"])
+	require.Len(t, export.AuditEvents, 1)
+	assert.Equal(t, "password.changed", export.AuditEvents[0]["type"])
+	assert.Equal(t, []map[string]interface{}{{"object": "company:7", "relation": "recruiter"}}, export.Relations)
+}
+
+func TestDeleteMeHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectCurrentPassword(t, mock, "my password")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET deleted_at = \\$1, purge_after = \\$2").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET token_version = token_version \\+ 1 WHERE username = \\$1").
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\)").
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 2))
+	for _, table := range []string{"user_roles", "user_groups", "password_reset_tokens", "email_verification_tokens"} {
+		mock.ExpectExec("DELETE FROM " + table + " WHERE user_id = \\$1").
+			WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("DELETE FROM relation_tuples WHERE \\(subject_type = 'user' AND subject_id = \\$1\\)").
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("account.deleted", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.DeleteMeHandler(rec, withClaims(jsonRequest("DELETE", "/me", `{"password":"my password"}`), userClaims("alice")))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Contains(t, rec.Body.String(), "purgeAfter")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMeHandler_WrongPassword(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectCurrentPassword(t, mock, "my password")
+
+	rec := httptest.NewRecorder()
+	handlers.DeleteMeHandler(rec, withClaims(jsonRequest("DELETE", "/me", `{"password":"guess"}`), userClaims("alice")))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
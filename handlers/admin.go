@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// namePattern restricts role, permission and group names to identifiers that
+// are safe to embed in tokens and logs.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// pathID parses the named mux path variable as a positive integer id.
+func pathID(r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	return id, err == nil && id > 0
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres
+// foreign_key_violation, i.e. a referenced row does not exist.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// validWindow reports whether a grant's optional validity bounds are in
+// order.
+func validWindow(from, to *time.Time) bool {
+	return from == nil || to == nil || to.After(*from)
+}
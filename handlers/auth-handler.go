@@ -2,12 +2,14 @@
 package handlers
 
 import (
+	"auth-service/audit"
 	"auth-service/db"
 	"auth-service/models"
 	jwt "auth-service/utils"
 	"database/sql"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 
@@ -29,6 +31,35 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Username and password are required", http.StatusBadRequest)
 		return
 	}
+	if audit.ReservedName(user.Username) {
+		http.Error(w, "Username is reserved", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(user.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validEmail(user.Email) {
+		http.Error(w, "A valid email address is required", http.StatusBadRequest)
+		return
+	}
+	// Role and Roles may name the same role; each is granted once.
+	var roles []string
+	for _, role := range append([]string{user.Role}, user.Roles...) {
+		if role != "" && !contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	allowed := registrationRoles()
+	if len(roles) == 0 {
+		roles = allowed[:1]
+	}
+	for _, role := range roles {
+		if !contains(allowed, role) {
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
@@ -38,22 +69,69 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert the new user into the database.
-	_, err = db.DB.Exec("INSERT INTO users (username, password, role) VALUES ($1, $2, $3)",
-		user.Username, string(hashedPassword), user.Role)
+	// Insert the new user and their roles in one transaction.
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow("INSERT INTO users (username, password, email) VALUES ($1, $2, $3) RETURNING id",
+		user.Username, string(hashedPassword), user.Email).Scan(&userID)
 	if err != nil {
 		log.Printf("Error inserting user into database: %v", err)
 		http.Error(w, "User already exists or database error", http.StatusConflict)
 		return
 	}
+	for _, role := range roles {
+		res, err := tx.Exec("INSERT INTO user_roles (user_id, role_id) SELECT $1, id FROM roles WHERE name = $2",
+			userID, role)
+		if err != nil {
+			log.Printf("Error assigning role: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Invalid role", http.StatusBadRequest)
+			return
+		}
+	}
+	verificationToken, err := createVerificationToken(tx, userID, user.Email)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The account exists even if the mail cannot be sent; the user can ask
+	// for another link.
+	if err := mailVerification(user.Username, user.Email, verificationToken); err != nil {
+		log.Printf("Error sending verification email to %s: %v", user.Username, err)
+	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(JSONResponse{"message": "User registered successfully"})
+	json.NewEncoder(w).Encode(JSONResponse{"message": "User registered successfully; check your email to verify your address"})
+}
+
+// loginRequest is the body accepted by LoginHandler. Audience optionally asks
+// for a token addressed to a specific downstream service.
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Audience string `json:"audience"`
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	var user models.Users
+	var user loginRequest
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
@@ -64,10 +142,19 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Username and password are required", http.StatusBadRequest)
 		return
 	}
+	if user.Audience == "" {
+		user.Audience = jwt.DefaultAudience()
+	} else if !jwt.AudienceAllowed(user.Audience) {
+		http.Error(w, "Audience is not allowed", http.StatusBadRequest)
+		return
+	}
 
-	// Retrieve the user's password and role from the database
-	var storedPassword, role string
-	err := db.DB.QueryRow("SELECT password, role FROM users WHERE username = $1", user.Username).Scan(&storedPassword, &role)
+	// Retrieve the user's password and token version from the database
+	var storedPassword string
+	var userID, tokenVersion int
+	var emailVerified bool
+	err := db.DB.QueryRow("SELECT id, password, token_version, email_verified FROM users WHERE username = $1 AND deleted_at IS NULL", user.Username).
+		Scan(&userID, &storedPassword, &tokenVersion, &emailVerified)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
@@ -80,34 +167,146 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Verify the password hash
 	if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(user.Password)); err != nil {
+		recordLogin(r, user.Username, audit.LoginFailed, "invalid password")
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
+	// Only checked once the password is known to be right, so the answer
+	// does not reveal anything about other people's accounts.
+	if !emailVerified {
+		recordLogin(r, user.Username, audit.LoginFailed, "email not verified")
+		http.Error(w, "Email address has not been verified", http.StatusForbidden)
+		return
+	}
+
+	roles, err := loadUserRoles(db.DB, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// A DPoP proof at login binds the issued tokens to the client's key.
+	var dpopJKT string
+	if r.Header.Get("DPoP") != "" {
+		proof, err := jwt.VerifyDPoPProof(r.Header.Get("DPoP"), r.Method, jwt.RequestURL(r), "")
+		if err != nil {
+			http.Error(w, "Invalid DPoP proof", http.StatusBadRequest)
+			return
+		}
+		dpopJKT = proof.JKT
+	}
 
-	// Generate JWT token using the revised GenerateToken function (with username and role)
-	token, err := jwt.GenerateToken(user.Username, role)
+	// Generate JWT token for the requested audience
+	token, err := jwt.GenerateUserToken(jwt.TokenParams{
+		Username:     user.Username,
+		Roles:        roles,
+		Audience:     user.Audience,
+		TokenVersion: tokenVersion,
+		DPoPJKT:      dpopJKT,
+	})
 	if err != nil {
 		log.Printf("Error generating token: %v", err)
 		http.Error(w, "Could not generate token", http.StatusInternalServerError)
 		return
 	}
 
-	// Return the token
-	json.NewEncoder(w).Encode(JSONResponse{"token": token})
+	// Start a new refresh token family for this login.
+	refreshToken, err := issueRefreshToken(db.DB, refreshTokenParams{
+		Username: user.Username,
+		Audience: user.Audience,
+		DPoPJKT:  dpopJKT,
+	})
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v", err)
+		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+
+	recordLogin(r, user.Username, audit.LoginSucceeded, "")
+
+	// Return the token pair
+	json.NewEncoder(w).Encode(tokenResponse(token, refreshToken, dpopJKT))
+}
+
+// recordLogin adds a sign-in attempt on an existing account to the user's
+// login history. Failing to record it does not fail the login.
+func recordLogin(r *http.Request, username, eventType, reason string) {
+	details := map[string]interface{}{"ip": r.RemoteAddr, "userAgent": r.UserAgent()}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		details["ip"] = host
+	}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	err := audit.Record(db.DB, audit.Event{Type: eventType, Actor: username, Subject: username, Details: details})
+	if err != nil {
+		log.Printf("Error recording login for %s: %v", username, err)
+	}
 }
 
 func AuthenticateHandler(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("userClaims").(*utils.Claims)
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	w.Header().Set("Content-Type", "application/json")
+
+	// Behind AuthMiddleware the claims are already in the context; otherwise
+	// validate the bearer token here so the endpoint can report why it failed.
+	claims, ok := r.Context().Value("userClaims").(*jwt.Claims)
+	if !ok {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			json.NewEncoder(w).Encode(JSONResponse{"valid": false, "message": "No token provided"})
+			return
+		}
+
+		var isExpired bool
+		var err error
+		claims, isExpired, err = jwt.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+		if isExpired {
+			json.NewEncoder(w).Encode(JSONResponse{"valid": false, "message": "Token has expired"})
+			return
+		}
+		if err != nil {
+			json.NewEncoder(w).Encode(JSONResponse{"valid": false, "message": "Invalid token"})
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{
 		"valid":    true,
 		"message":  "Token is valid",
 		"username": claims.Username,
 		"role":     claims.Role,
+		"roles":    claims.Roles,
 	})
 }
 
+// LogoutHandler revokes the caller's access token, and the refresh token
+// family if a refresh token is supplied in the body. Logging out with a missing
+// or already invalid token still succeeds, so clients can always clear state.
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		_, token := jwt.AuthorizationToken(authHeader)
+		claims, _, err := jwt.ValidateToken(token)
+		if err == nil && claims.ID != "" {
+			if err := jwt.RevokeToken(claims); err != nil {
+				log.Printf("Error revoking token: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err == nil && req.RefreshToken != "" {
+		if err := revokeRefreshFamilyByToken(db.DB, req.RefreshToken); err != nil {
+			log.Printf("Error revoking refresh token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(JSONResponse{"message": "Logged out successfully"})
 }
@@ -36,11 +36,19 @@ func TestRegisterHandler(t *testing.T) {
 	defer cleanup()
 
 	// Use a valid role "jobseeker" (or "employer")
-	mock.ExpectExec("INSERT INTO users").
-		WithArgs("testuser", sqlmock.AnyArg(), "jobseeker").
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	user := models.Users{Username: "testuser", Password: "password", Role: "jobseeker"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", sqlmock.AnyArg(), "testuser@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO user_roles").
+		WithArgs(1, "jobseeker").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO email_verification_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, "testuser@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	user := models.Users{Username: "testuser", Password: "password", Email: "testuser@example.com", Role: "jobseeker"}
 	body, err := json.Marshal(user)
 	assert.NoError(t, err)
 
@@ -52,6 +60,34 @@ func TestRegisterHandler(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, rec.Code)
 }
 
+// A role given both as role and in roles is granted once.
+func TestRegisterHandler_DuplicateRole(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", sqlmock.AnyArg(), "testuser@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO user_roles").
+		WithArgs(1, "jobseeker").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO email_verification_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, "testuser@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{"username":"testuser","password":"password","email":"testuser@example.com",
+		"role":"jobseeker","roles":["jobseeker","jobseeker"]}`
+	req := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handlers.RegisterHandler(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 // Invalid JSON payload.
 func TestRegisterHandler_InvalidJSON(t *testing.T) {
 	req := httptest.NewRequest("POST", "/register", strings.NewReader("{invalid-json"))
@@ -115,11 +151,13 @@ func TestRegisterHandler_DBError(t *testing.T) {
 	mock, cleanup := setupMockDB()
 	defer cleanup()
 
-	mock.ExpectExec("INSERT INTO users").
-		WithArgs("testuser", sqlmock.AnyArg(), "jobseeker").
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", sqlmock.AnyArg(), "testuser@example.com").
 		WillReturnError(sql.ErrConnDone) // simulate connection error
+	mock.ExpectRollback()
 
-	user := models.Users{Username: "testuser", Password: "password"}
+	user := models.Users{Username: "testuser", Password: "password", Email: "testuser@example.com"}
 	body, _ := json.Marshal(user)
 	req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
@@ -129,6 +167,87 @@ func TestRegisterHandler_DBError(t *testing.T) {
 	assert.Equal(t, http.StatusConflict, rec.Code)
 }
 
+// Passwords must meet the password policy.
+func TestRegisterHandler_WeakPassword(t *testing.T) {
+	_, cleanup := setupMockDB()
+	defer cleanup()
+
+	user := models.Users{Username: "testuser", Password: "pass"}
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handlers.RegisterHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "password must be at least 8 characters")
+}
+
+// Registration needs a valid email address.
+func TestRegisterHandler_InvalidEmail(t *testing.T) {
+	for _, email := range []string{"", "not-an-email", "Alice <alice@example.com>"} {
+		user := models.Users{Username: "testuser", Password: "password", Email: email}
+		body, _ := json.Marshal(user)
+		req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handlers.RegisterHandler(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, email)
+	}
+}
+
+// Names the audit log uses for the service itself cannot be registered.
+func TestRegisterHandler_ReservedUsername(t *testing.T) {
+	for _, username := range []string{"system", "deleted-user", "user:7", "group:acme"} {
+		user := models.Users{Username: username, Password: "password", Email: "someone@example.com"}
+		body, _ := json.Marshal(user)
+		req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handlers.RegisterHandler(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, username)
+	}
+}
+
+// Roles outside REGISTRATION_ROLES cannot be self-assigned.
+func TestRegisterHandler_RoleNotSelfAssignable(t *testing.T) {
+	user := models.Users{Username: "testuser", Password: "password", Email: "testuser@example.com", Roles: []string{"admin"}}
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handlers.RegisterHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// Roles missing from the roles table are rejected and nothing is committed.
+func TestRegisterHandler_UnknownRole(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("testuser", sqlmock.AnyArg(), "testuser@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO user_roles").
+		WithArgs(1, "employer").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	user := models.Users{Username: "testuser", Password: "password", Email: "testuser@example.com", Role: "employer"}
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handlers.RegisterHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 // --------------------
 // LoginHandler Tests
 // --------------------
@@ -137,14 +256,25 @@ func TestRegisterHandler_DBError(t *testing.T) {
 func TestLoginHandler(t *testing.T) {
 	mock, cleanup := setupMockDB()
 	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
 	assert.NoError(t, err)
 
-	mock.ExpectQuery(`SELECT password, role FROM users WHERE username = \$1`).
+	mock.ExpectQuery(`SELECT id, password, token_version, email_verified FROM users WHERE username = \$1`).
 		WithArgs("testuser").
-		WillReturnRows(sqlmock.NewRows([]string{"password", "role"}).
-			AddRow(string(hashedPassword), "jobseeker"))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password", "token_version", "email_verified"}).
+			AddRow(1, string(hashedPassword), 0, true))
+	mock.ExpectQuery("SELECT r.name FROM roles r").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jobseeker").AddRow("employer"))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs("testuser", sqlmock.AnyArg(), "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("login.succeeded", "testuser", "testuser", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	user := models.Users{Username: "testuser", Password: "password"}
 	body, _ := json.Marshal(user)
@@ -154,6 +284,17 @@ func TestLoginHandler(t *testing.T) {
 
 	handlers.LoginHandler(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.NotEmpty(t, response["token"])
+	assert.NotEmpty(t, response["refreshToken"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	claims, _, err := jwt.ValidateToken(response["token"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jobseeker", "employer"}, claims.Roles)
+	assert.Equal(t, "jobseeker", claims.Role)
 }
 
 // Invalid JSON payload for login.
@@ -195,7 +336,7 @@ func TestLoginHandler_UserNotFound(t *testing.T) {
 	mock, cleanup := setupMockDB()
 	defer cleanup()
 
-	mock.ExpectQuery(`SELECT password, role FROM users WHERE username = \$1`).
+	mock.ExpectQuery(`SELECT id, password, token_version, email_verified FROM users WHERE username = \$1`).
 		WithArgs("testuser").
 		WillReturnError(sql.ErrNoRows)
 
@@ -214,7 +355,7 @@ func TestLoginHandler_DBError(t *testing.T) {
 	mock, cleanup := setupMockDB()
 	defer cleanup()
 
-	mock.ExpectQuery(`SELECT password, role FROM users WHERE username = \$1`).
+	mock.ExpectQuery(`SELECT id, password, token_version, email_verified FROM users WHERE username = \$1`).
 		WithArgs("testuser").
 		WillReturnError(sql.ErrConnDone)
 
@@ -236,10 +377,13 @@ func TestLoginHandler_WrongPassword(t *testing.T) {
 	// Create a hash for a different password so that the comparison fails.
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("different_password"), bcrypt.DefaultCost)
 
-	mock.ExpectQuery(`SELECT password, role FROM users WHERE username = \$1`).
+	mock.ExpectQuery(`SELECT id, password, token_version, email_verified FROM users WHERE username = \$1`).
 		WithArgs("testuser").
-		WillReturnRows(sqlmock.NewRows([]string{"password", "role"}).
-			AddRow(string(hashedPassword), "jobseeker"))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password", "token_version", "email_verified"}).
+			AddRow(1, string(hashedPassword), 0, true))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("login.failed", "testuser", "testuser", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	user := models.Users{Username: "testuser", Password: "password"}
 	body, _ := json.Marshal(user)
@@ -249,6 +393,33 @@ func TestLoginHandler_WrongPassword(t *testing.T) {
 
 	handlers.LoginHandler(rec, req)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Unverified accounts cannot sign in.
+func TestLoginHandler_EmailNotVerified(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	mock.ExpectQuery(`SELECT id, password, token_version, email_verified FROM users WHERE username = \$1`).
+		WithArgs("testuser").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password", "token_version", "email_verified"}).
+			AddRow(1, string(hashedPassword), 0, false))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("login.failed", "testuser", "testuser", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	user := models.Users{Username: "testuser", Password: "password"}
+	body, _ := json.Marshal(user)
+	req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handlers.LoginHandler(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Email address has not been verified")
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // --------------------
@@ -340,3 +511,23 @@ func TestLogoutHandler(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "Logged out successfully", response["message"])
 }
+
+// Logout denylists the bearer token.
+func TestLogoutHandler_RevokesToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	jwt.SetDenylist(jwt.NewMemoryDenylist())
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	handlers.LogoutHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, _, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+}
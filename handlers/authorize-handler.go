@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"auth-service/authz"
+	jwt "auth-service/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// maxAuthorizationChecks bounds the size of a batch /authorize request.
+const maxAuthorizationChecks = 200
+
+// authorizationCheck asks about an action on a resource, or on a single
+// instance of it when ID is set.
+type authorizationCheck struct {
+	Resource string `json:"resource"`
+	ID       string `json:"id,omitempty"`
+	Action   string `json:"action"`
+}
+
+// authorizeRequest names the user either by subject (username) or by one of
+// their access tokens, and asks about a single resource and action or about a
+// batch of checks.
+type authorizeRequest struct {
+	Subject string `json:"subject"`
+	Token   string `json:"token"`
+	authorizationCheck
+	Checks []authorizationCheck `json:"checks"`
+}
+
+// decision describes the outcome of one check. Rule is the grant that
+// allowed it and is null on deny.
+func decision(check authorizationCheck, grant *authz.Grant, reason string) JSONResponse {
+	response := JSONResponse{
+		"resource": check.Resource,
+		"action":   check.Action,
+		"allowed":  grant != nil,
+		"decision": "deny",
+		"rule":     grant,
+	}
+	if check.ID != "" {
+		response["id"] = check.ID
+	}
+	if grant != nil {
+		response["decision"] = "allow"
+	} else if reason != "" {
+		response["reason"] = reason
+	}
+	return response
+}
+
+// AuthorizeHandler is the central policy decision point for other services.
+// An authenticated client asks whether a user may perform an action on a
+// resource and gets back allow or deny plus the rule that matched. Sending
+// "checks" instead of a single resource and action answers many questions in
+// one round trip, e.g. which of a list of job IDs a user may edit. Unknown
+// users and inactive tokens are denied.
+func AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticateClient(w, r); !ok {
+		return
+	}
+
+	var req authorizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if (req.Subject == "") == (req.Token == "") {
+		http.Error(w, "Exactly one of subject and token is required", http.StatusBadRequest)
+		return
+	}
+	batch := len(req.Checks) > 0
+	checks := req.Checks
+	if !batch {
+		checks = []authorizationCheck{req.authorizationCheck}
+	}
+	if len(checks) > maxAuthorizationChecks {
+		http.Error(w, fmt.Sprintf("At most %d checks are allowed per request", maxAuthorizationChecks), http.StatusBadRequest)
+		return
+	}
+	for _, check := range checks {
+		if check.Resource == "" || check.Action == "" {
+			http.Error(w, "Every check needs a resource and an action", http.StatusBadRequest)
+			return
+		}
+	}
+
+	subject, reason := req.Subject, ""
+	if req.Token != "" {
+		// Tokens for any of our audiences may be checked; the caller is
+		// usually the service the token was issued for.
+		opts := jwt.DefaultValidationOptions()
+		opts.Audiences = nil
+		claims, _, err := jwt.ValidateTokenWithOptions(req.Token, opts)
+		if err != nil {
+			subject, reason = "", "token is not active"
+		} else if claims.Restricted() {
+			// The user's permissions would overstate what a scoped or
+			// delegated token may do.
+			subject, reason = "", "token is scoped or delegated"
+		} else {
+			subject = claims.Subject
+		}
+	}
+
+	var permissions *authz.Permissions
+	if subject != "" {
+		var err error
+		permissions, err = authz.Default().SubjectPermissions(subject)
+		if errors.Is(err, authz.ErrUnknownUser) {
+			reason = "unknown subject"
+		} else if err != nil {
+			log.Printf("Error resolving permissions for %s: %v", subject, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	results := make([]JSONResponse, len(checks))
+	for i, check := range checks {
+		var matched *authz.Grant
+		if permissions != nil {
+			want := authz.Permission{Resource: check.Resource, Instance: check.ID, Action: check.Action}
+			if grant, ok := permissions.Match(want); ok {
+				matched = &grant
+			}
+		}
+		results[i] = decision(check, matched, reason)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !batch {
+		results[0]["subject"] = subject
+		json.NewEncoder(w).Encode(results[0])
+		return
+	}
+	json.NewEncoder(w).Encode(JSONResponse{"subject": subject, "results": results})
+}
@@ -0,0 +1,149 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	jwt "auth-service/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func authorizeRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/authorize", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("gateway", "s3cret")
+	return req
+}
+
+// expectGrants sets up the authz lookups for alice, an employer who may
+// publish jobs, do anything to job 1234 and, through the acme group, view
+// jobs.
+func expectGrants(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT id FROM users WHERE username = \$1`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT p.resource, p.instance, p.action").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"resource", "instance", "action", "role", "via", "inherited_from"}).
+			AddRow("job", "", "publish", "employer", "", "").
+			AddRow("job", "", "view", "recruiter", "acme", "employer").
+			AddRow("job", "1234", "*", "employer", "", ""))
+}
+
+func TestAuthorizeHandler_Allow(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	expectGrants(mock)
+
+	rec := httptest.NewRecorder()
+	handlers.AuthorizeHandler(rec, authorizeRequest(`{"subject":"alice","resource":"job","action":"publish"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"subject":"alice","resource":"job","action":"publish","allowed":true,"decision":"allow",
+		"rule":{"resource":"job","action":"publish","role":"employer"}}`, rec.Body.String())
+}
+
+func TestAuthorizeHandler_Batch(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	expectClient(t, mock)
+	expectGrants(mock)
+
+	token, err := jwt.GenerateToken("alice", "employer")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handlers.AuthorizeHandler(rec, authorizeRequest(`{"token":"`+token+`","checks":[
+		{"resource":"job","action":"view"},{"resource":"job","action":"delete"}]}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Subject string                   `json:"subject"`
+		Results []map[string]interface{} `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "alice", response.Subject)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, "allow", response.Results[0]["decision"])
+	assert.Equal(t, "acme", response.Results[0]["rule"].(map[string]interface{})["group"])
+	assert.Equal(t, "deny", response.Results[1]["decision"])
+	assert.Nil(t, response.Results[1]["rule"])
+}
+
+func TestAuthorizeHandler_InactiveToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.AuthorizeHandler(rec, authorizeRequest(`{"token":"not-a-token","resource":"job","action":"view"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "deny", response["decision"])
+	assert.Equal(t, "token is not active", response["reason"])
+}
+
+// A token from token exchange does not carry the user's full permissions.
+func TestAuthorizeHandler_ScopedToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	expectClient(t, mock)
+
+	claims := &jwt.Claims{Username: "alice", Scope: "jobs:read", Act: &jwt.Actor{Subject: "gateway"}}
+	claims.Subject = "alice"
+	token, err := jwt.IssueToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handlers.AuthorizeHandler(rec, authorizeRequest(`{"token":"`+token+`","resource":"job","action":"view"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "deny", response["decision"])
+	assert.Equal(t, "token is scoped or delegated", response["reason"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthorizeHandler_MissingAction(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.AuthorizeHandler(rec, authorizeRequest(`{"subject":"alice","resource":"job"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAuthorizeHandler_Instances(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	expectGrants(mock)
+
+	rec := httptest.NewRecorder()
+	handlers.AuthorizeHandler(rec, authorizeRequest(`{"subject":"alice","checks":[
+		{"resource":"job","id":"1234","action":"edit"},{"resource":"job","id":"5678","action":"edit"}]}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, "allow", response.Results[0]["decision"])
+	assert.Equal(t, "1234", response.Results[0]["id"])
+	assert.Equal(t, "deny", response.Results[1]["decision"])
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"auth-service/db"
+	"auth-service/models"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authenticateClient checks the client credentials on r, sent either with
+// HTTP Basic authentication or as client_id/client_secret form fields
+// (RFC 6749 section 2.3.1). It writes a 401 and returns false on failure.
+func authenticateClient(w http.ResponseWriter, r *http.Request) (*models.OAuthClients, bool) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID == "" || secret == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+		http.Error(w, "Client authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	client := &models.OAuthClients{ClientID: clientID}
+	err := db.DB.QueryRow("SELECT secret_hash, name FROM oauth_clients WHERE client_id = $1", clientID).
+		Scan(&client.SecretHash, &client.Name)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if err == sql.ErrNoRows || bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)) != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-service"`)
+		http.Error(w, "Invalid client credentials", http.StatusUnauthorized)
+		return nil, false
+	}
+	return client, true
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	jwt "auth-service/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// oauthError writes an RFC 6749 section 5.2 error response.
+func oauthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(JSONResponse{"error": code, "error_description": description})
+}
+
+// TokenExchangeHandler implements the OAuth 2.0 token exchange grant
+// (RFC 8693). An authenticated service client presents a user's access token
+// and receives a short-lived token for a narrower audience and scope, with an
+// "act" claim naming the client.
+func TokenExchangeHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	if r.PostFormValue("grant_type") != grantTypeTokenExchange {
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be "+grantTypeTokenExchange)
+		return
+	}
+	subjectToken := r.PostFormValue("subject_token")
+	if subjectToken == "" {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "subject_token is required")
+		return
+	}
+	if t := r.PostFormValue("subject_token_type"); t != tokenTypeAccessToken && t != tokenTypeJWT {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "unsupported subject_token_type")
+		return
+	}
+	if t := r.PostFormValue("requested_token_type"); t != "" && t != tokenTypeAccessToken {
+		oauthError(w, http.StatusBadRequest, "invalid_request", "unsupported requested_token_type")
+		return
+	}
+	audience := r.PostFormValue("audience")
+	if audience == "" || !jwt.AudienceAllowed(audience) {
+		oauthError(w, http.StatusBadRequest, "invalid_target", "audience is missing or not allowed")
+		return
+	}
+	// Exchanged tokens are for downstream services. One addressed to this
+	// service would pass AuthMiddleware and act as the user here.
+	if audience == jwt.DefaultAudience() {
+		oauthError(w, http.StatusBadRequest, "invalid_target", "audience must be a downstream service")
+		return
+	}
+
+	// The subject token may be addressed to any audience we issue for; the
+	// calling service is typically the one it was minted for.
+	opts := jwt.DefaultValidationOptions()
+	opts.Audiences = nil
+	subject, _, err := jwt.ValidateTokenWithOptions(subjectToken, opts)
+	if err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_grant", "subject_token is invalid")
+		return
+	}
+	// A DPoP-bound subject token is only usable by the holder of its key,
+	// so the caller must prove possession just as it would at a resource.
+	if subject.Cnf != nil && subject.Cnf.JKT != "" {
+		proof, err := jwt.VerifyDPoPProof(r.Header.Get("DPoP"), r.Method, jwt.RequestURL(r), subjectToken)
+		if err != nil || proof.JKT != subject.Cnf.JKT {
+			oauthError(w, http.StatusBadRequest, "invalid_dpop_proof", "a DPoP proof for the subject token's key is required")
+			return
+		}
+	}
+
+	token, ttl, err := jwt.ExchangeToken(subject, client.ClientID, audience, r.PostFormValue("scope"))
+	if errors.Is(err, jwt.ErrScopeNotAllowed) {
+		oauthError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error exchanging token: %v", err)
+		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+
+	response := JSONResponse{
+		"access_token":      token,
+		"issued_token_type": tokenTypeAccessToken,
+		"token_type":        "Bearer",
+		"expires_in":        int(ttl.Seconds()),
+	}
+	if subject.Cnf != nil && subject.Cnf.JKT != "" {
+		response["token_type"] = "DPoP"
+	}
+	if scope := r.PostFormValue("scope"); scope != "" {
+		response["scope"] = scope
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"auth-service/internal/testutil"
+	jwt "auth-service/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exchangeRequest(form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/token/exchange", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("gateway", "s3cret")
+	return req
+}
+
+func TestTokenExchangeHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	os.Setenv("JWT_ALLOWED_AUDIENCES", "applications-api")
+	defer os.Unsetenv("JWT_ALLOWED_AUDIENCES")
+	expectClient(t, mock)
+
+	userToken, err := jwt.GenerateToken("testuser", "jobseeker")
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handlers.TokenExchangeHandler(rec, exchangeRequest(url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {userToken},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":           {"applications-api"},
+		"scope":              {"applications:read"},
+	}))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "Bearer", response["token_type"])
+
+	claims, _, err := jwt.ValidateToken(response["access_token"].(string), "applications-api")
+	assert.NoError(t, err)
+	assert.Equal(t, "gateway", claims.Act.Subject)
+	assert.Equal(t, "applications:read", claims.Scope)
+}
+
+func TestTokenExchangeHandler_DPoPBoundSubject(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	os.Setenv("JWT_ALLOWED_AUDIENCES", "applications-api")
+	defer os.Unsetenv("JWT_ALLOWED_AUDIENCES")
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk, _ := jwt.PublicKeyToJWK(&key.PublicKey)
+	jkt, _ := jwt.Thumbprint(jwk)
+	userToken, err := jwt.GenerateUserToken(jwt.TokenParams{Username: "testuser", Role: "jobseeker", DPoPJKT: jkt})
+	require.NoError(t, err)
+	form := url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {userToken},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":           {"applications-api"},
+	}
+
+	// Without a proof the bound token cannot be exchanged.
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	rec := httptest.NewRecorder()
+	handlers.TokenExchangeHandler(rec, exchangeRequest(form))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_dpop_proof", response["error"])
+
+	// With a proof from the bound key the new token keeps the binding.
+	expectClient(t, mock)
+	req := exchangeRequest(form)
+	req.Header.Set("DPoP", testutil.DPoPProof(t, key, "POST", "http://example.com/token/exchange", userToken, time.Now()))
+	rec = httptest.NewRecorder()
+	handlers.TokenExchangeHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "DPoP", response["token_type"])
+
+	claims, _, err := jwt.ValidateToken(response["access_token"].(string), "applications-api")
+	require.NoError(t, err)
+	require.NotNil(t, claims.Cnf)
+	assert.Equal(t, jkt, claims.Cnf.JKT)
+}
+
+func TestTokenExchangeHandler_AudienceNotAllowed(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.TokenExchangeHandler(rec, exchangeRequest(url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {"x"},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":           {"somewhere-else"},
+	}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_target", response["error"])
+}
+
+func TestTokenExchangeHandler_DefaultAudience(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_AUDIENCE", "auth-service")
+	defer os.Unsetenv("JWT_AUDIENCE")
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.TokenExchangeHandler(rec, exchangeRequest(url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {"x"},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"audience":           {"auth-service"},
+	}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_target", response["error"])
+}
+
+func TestTokenExchangeHandler_WrongGrantType(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.TokenExchangeHandler(rec, exchangeRequest(url.Values{"grant_type": {"password"}}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
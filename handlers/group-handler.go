@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"auth-service/authz"
+	"auth-service/db"
+	"auth-service/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// decodeGroup reads and validates a group from the request body.
+func decodeGroup(w http.ResponseWriter, r *http.Request) (models.Groups, bool) {
+	var group models.Groups
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return group, false
+	}
+	if !namePattern.MatchString(group.Name) {
+		http.Error(w, "Group name must be 1-64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return group, false
+	}
+	return group, true
+}
+
+// ListGroupsHandler returns every group.
+func ListGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	rows, err := db.DB.Query("SELECT id, name, description FROM groups ORDER BY id")
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	groups := []models.Groups{}
+	for rows.Next() {
+		var group models.Groups
+		if err := rows.Scan(&group.ID, &group.Name, &group.Description); err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"groups": groups})
+}
+
+// GetGroupHandler returns a group together with its members and roles.
+func GetGroupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+
+	group := models.Groups{ID: id}
+	err := db.DB.QueryRow("SELECT name, description FROM groups WHERE id = $1", id).
+		Scan(&group.Name, &group.Description)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := queryStrings(db.DB, `SELECT u.username FROM users u
+		JOIN user_groups ug ON ug.user_id = u.id
+		WHERE ug.group_id = $1 ORDER BY u.username`, id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	roles, err := queryStrings(db.DB, `SELECT r.name FROM roles r
+		JOIN group_roles gr ON gr.role_id = r.id
+		WHERE gr.group_id = $1 ORDER BY r.id`, id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{
+		"id":          group.ID,
+		"name":        group.Name,
+		"description": group.Description,
+		"members":     members,
+		"roles":       roles,
+	})
+}
+
+// CreateGroupHandler adds a group. Names are unique.
+func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	group, ok := decodeGroup(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DB.QueryRow("INSERT INTO groups (name, description) VALUES ($1, $2) RETURNING id",
+		group.Name, group.Description).Scan(&group.ID)
+	if isUniqueViolation(err) {
+		http.Error(w, "A group with that name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Group %s created", group.Name)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+// UpdateGroupHandler renames a group or changes its description.
+func UpdateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+	group, ok := decodeGroup(w, r)
+	if !ok {
+		return
+	}
+	group.ID = id
+
+	res, err := db.DB.Exec("UPDATE groups SET name = $1, description = $2 WHERE id = $3",
+		group.Name, group.Description, id)
+	if isUniqueViolation(err) {
+		http.Error(w, "A group with that name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(group)
+}
+
+// DeleteGroupHandler removes a group. Its members lose the roles they held
+// through it.
+func DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM groups WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Group deleted"})
+}
+
+// AddGroupMemberHandler adds a user to a group. Adding an existing member is
+// not an error.
+func AddGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+	var member models.UserGroups
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil || member.UserID <= 0 {
+		http.Error(w, "userId is required", http.StatusBadRequest)
+		return
+	}
+	member.GroupID = id
+
+	_, err := db.DB.Exec(`INSERT INTO user_groups (user_id, group_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, member.UserID, member.GroupID)
+	if isForeignKeyViolation(err) {
+		http.Error(w, "User or group not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authz.InvalidateAll()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveGroupMemberHandler removes a user from a group.
+func RemoveGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+	userID, ok := pathID(r, "userId")
+	if !ok {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM user_groups WHERE group_id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "User is not a member of that group", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Member removed from group"})
+}
+
+// AddGroupRoleHandler gives every member of a group a role, optionally only
+// between validFrom and expiresAt.
+func AddGroupRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+	var grant models.GroupRoles
+	if err := json.NewDecoder(r.Body).Decode(&grant); err != nil || grant.RoleID <= 0 {
+		http.Error(w, "roleId is required", http.StatusBadRequest)
+		return
+	}
+	if !validWindow(grant.ValidFrom, grant.ExpiresAt) {
+		http.Error(w, "expiresAt must be after validFrom", http.StatusBadRequest)
+		return
+	}
+	grant.GroupID = id
+
+	// Granting a role the group already has replaces its validity window.
+	_, err := db.DB.Exec(`INSERT INTO group_roles (group_id, role_id, valid_from, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (group_id, role_id) DO UPDATE SET valid_from = EXCLUDED.valid_from, expires_at = EXCLUDED.expires_at`,
+		grant.GroupID, grant.RoleID, grant.ValidFrom, grant.ExpiresAt)
+	if isForeignKeyViolation(err) {
+		http.Error(w, "Group or role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authz.InvalidateAll()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// RemoveGroupRoleHandler takes a role away from a group.
+func RemoveGroupRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid group id", http.StatusBadRequest)
+		return
+	}
+	roleID, ok := pathID(r, "roleId")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2", id, roleID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Group does not have that role", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Role removed from group"})
+}
@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateGroupHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO groups").
+		WithArgs("acme-hiring", "Acme hiring team").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	req := httptest.NewRequest("POST", "/admin/groups", strings.NewReader(`{"name":"acme-hiring","description":"Acme hiring team"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreateGroupHandler(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id":5,"name":"acme-hiring","description":"Acme hiring team"}`, rec.Body.String())
+}
+
+func TestCreateGroupHandler_Duplicate(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO groups").
+		WithArgs("acme-hiring", "").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	req := httptest.NewRequest("POST", "/admin/groups", strings.NewReader(`{"name":"acme-hiring"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreateGroupHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestGetGroupHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT name, description FROM groups WHERE id = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description"}).AddRow("acme-hiring", ""))
+	mock.ExpectQuery("SELECT u.username FROM users u").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("alice").AddRow("bob"))
+	mock.ExpectQuery("SELECT r.name FROM roles r").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("employer"))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/admin/groups/5", nil), map[string]string{"id": "5"})
+	rec := httptest.NewRecorder()
+	handlers.GetGroupHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":5,"name":"acme-hiring","description":"","members":["alice","bob"],"roles":["employer"]}`,
+		rec.Body.String())
+}
+
+func TestAddGroupMemberHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectExec("INSERT INTO user_groups").
+		WithArgs(42, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/groups/5/members", strings.NewReader(`{"userId":42}`)),
+		map[string]string{"id": "5"})
+	rec := httptest.NewRecorder()
+	handlers.AddGroupMemberHandler(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveGroupRoleHandler_NotAssigned(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectExec("DELETE FROM group_roles").
+		WithArgs(5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/groups/5/roles/2", nil),
+		map[string]string{"id": "5", "roleId": "2"})
+	rec := httptest.NewRecorder()
+	handlers.RemoveGroupRoleHandler(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"auth-service/db"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// IntrospectHandler implements OAuth 2.0 token introspection (RFC 7662) for
+// consumers that cannot verify tokens themselves. Access tokens go through
+// ValidateToken, so expiry, signature, issuer and revocation are all taken
+// into account; refresh tokens are looked up in the database. Any token that
+// is not currently usable is reported as {"active": false}.
+func IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticateClient(w, r); !ok {
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var response JSONResponse
+	var err error
+	if r.PostFormValue("token_type_hint") == "refresh_token" {
+		response, err = introspectRefreshToken(token)
+	} else {
+		response = introspectAccessToken(token)
+		if !response["active"].(bool) {
+			response, err = introspectRefreshToken(token)
+		}
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// introspectAccessToken describes a JWT access token. The audience is not
+// restricted: callers receive aud in the response and decide for themselves.
+func introspectAccessToken(token string) JSONResponse {
+	opts := jwt.DefaultValidationOptions()
+	opts.Audiences = nil
+	claims, _, err := jwt.ValidateTokenWithOptions(token, opts)
+	if err != nil {
+		return JSONResponse{"active": false}
+	}
+
+	response := JSONResponse{
+		"active":     true,
+		"token_type": "Bearer",
+		"sub":        claims.Subject,
+		"username":   claims.Username,
+		"role":       claims.Role,
+		"roles":      claims.Roles,
+		"jti":        claims.ID,
+	}
+	if claims.Scope != "" {
+		response["scope"] = claims.Scope
+	}
+	if claims.Act != nil {
+		response["act"] = claims.Act
+	}
+	// Resource servers must see the key binding, or a stolen DPoP-bound
+	// token would pass as a bearer token (RFC 9449 section 6.2).
+	if claims.Cnf != nil && claims.Cnf.JKT != "" {
+		response["token_type"] = "DPoP"
+		response["cnf"] = claims.Cnf
+	}
+	if claims.Issuer != "" {
+		response["iss"] = claims.Issuer
+	}
+	if len(claims.Audience) > 0 {
+		response["aud"] = claims.Audience
+	}
+	if claims.ExpiresAt != nil {
+		response["exp"] = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response["iat"] = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		response["nbf"] = claims.NotBefore.Unix()
+	}
+	return response
+}
+
+// introspectRefreshToken describes an opaque refresh token.
+func introspectRefreshToken(token string) (JSONResponse, error) {
+	var (
+		username             string
+		expiresAt, createdAt time.Time
+		rotatedAt, revokedAt sql.NullTime
+	)
+	err := db.DB.QueryRow(`SELECT username, expires_at, created_at, rotated_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1`, jwt.HashOpaqueToken(token)).
+		Scan(&username, &expiresAt, &createdAt, &rotatedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return JSONResponse{"active": false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rotatedAt.Valid || revokedAt.Valid || time.Now().After(expiresAt) {
+		return JSONResponse{"active": false}, nil
+	}
+	return JSONResponse{
+		"active":     true,
+		"token_type": "refresh_token",
+		"sub":        username,
+		"username":   username,
+		"exp":        expiresAt.Unix(),
+		"iat":        createdAt.Unix(),
+	}, nil
+}
@@ -0,0 +1,117 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// expectClient sets up a lookup for the "gateway" client with secret "s3cret".
+func expectClient(t *testing.T, mock sqlmock.Sqlmock) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	mock.ExpectQuery(`SELECT secret_hash, name FROM oauth_clients WHERE client_id = \$1`).
+		WithArgs("gateway").
+		WillReturnRows(sqlmock.NewRows([]string{"secret_hash", "name"}).AddRow(string(hash), "API gateway"))
+}
+
+func introspectRequest(form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/introspect", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("gateway", "s3cret")
+	return req
+}
+
+// Active access token.
+func TestIntrospectHandler_ActiveToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	expectClient(t, mock)
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handlers.IntrospectHandler(rec, introspectRequest(url.Values{"token": {token}}))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, true, response["active"])
+	assert.Equal(t, "testuser", response["sub"])
+	assert.Equal(t, "employer", response["role"])
+	assert.NotNil(t, response["exp"])
+}
+
+// DPoP-bound tokens report their key binding.
+func TestIntrospectHandler_DPoPBoundToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	expectClient(t, mock)
+
+	token, err := jwt.GenerateUserToken(jwt.TokenParams{Username: "testuser", Role: "employer", DPoPJKT: "thumbprint"})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handlers.IntrospectHandler(rec, introspectRequest(url.Values{"token": {token}}))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, true, response["active"])
+	assert.Equal(t, "DPoP", response["token_type"])
+	assert.Equal(t, map[string]interface{}{"jkt": "thumbprint"}, response["cnf"])
+}
+
+// Unknown tokens are reported as inactive.
+func TestIntrospectHandler_InactiveToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	mock.ExpectQuery("SELECT username, expires_at, created_at, rotated_at, revoked_at").
+		WillReturnError(sql.ErrNoRows)
+
+	rec := httptest.NewRecorder()
+	handlers.IntrospectHandler(rec, introspectRequest(url.Values{"token": {"garbage"}}))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"active": false}`, rec.Body.String())
+}
+
+// Bad client credentials.
+func TestIntrospectHandler_BadClient(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	req := introspectRequest(url.Values{"token": {"x"}})
+	req.SetBasicAuth("gateway", "wrong")
+	rec := httptest.NewRecorder()
+	handlers.IntrospectHandler(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+// No client credentials.
+func TestIntrospectHandler_NoClient(t *testing.T) {
+	req := httptest.NewRequest("POST", "/introspect", strings.NewReader("token=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	handlers.IntrospectHandler(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
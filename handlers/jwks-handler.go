@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	jwt "auth-service/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// JWKSHandler publishes the public signing keys as a JSON Web Key Set so that
+// other services can verify tokens without holding any secret.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	set, err := jwt.PublicJWKS()
+	if err != nil {
+		log.Printf("Error building JWKS: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(set)
+}
@@ -0,0 +1,29 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Unsetenv("JWT_SIGNING_ALG")
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+
+	handlers.JWKSHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var response map[string][]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Contains(t, response, "keys")
+	assert.Empty(t, response["keys"])
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"auth-service/db"
+	"auth-service/models"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxDisplayNameLength bounds display names, in characters.
+const maxDisplayNameLength = 100
+
+// localePattern accepts BCP 47 style tags such as "en", "en-GB" or
+// "zh-Hant-TW".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// profile is the body of GET /me. Roles are the user's effective roles,
+// including group and inherited ones.
+type profile struct {
+	ID            int      `json:"id"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"emailVerified"`
+	PendingEmail  string   `json:"pendingEmail,omitempty"`
+	DisplayName   string   `json:"displayName"`
+	Locale        string   `json:"locale"`
+	Timezone      string   `json:"timezone"`
+	Roles         []string `json:"roles"`
+	Groups        []string `json:"groups"`
+}
+
+// rowQueryer is satisfied by both *sql.DB and *sql.Tx.
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadUser reads the profile columns of a user. The row is locked when
+// forUpdate is set, so q must then be a transaction.
+func loadUser(q rowQueryer, username string, forUpdate bool) (models.Users, error) {
+	query := `SELECT id, username, COALESCE(email, ''), email_verified, COALESCE(pending_email, ''),
+		display_name, locale, timezone FROM users WHERE username = $1 AND deleted_at IS NULL`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var u models.Users
+	err := q.QueryRow(query, username).Scan(&u.ID, &u.Username, &u.Email, &u.EmailVerified, &u.PendingEmail,
+		&u.DisplayName, &u.Locale, &u.Timezone)
+	return u, err
+}
+
+// loadProfile builds the GET /me view of a user.
+func loadProfile(u models.Users) (profile, error) {
+	p := profile{
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		PendingEmail:  u.PendingEmail,
+		DisplayName:   u.DisplayName,
+		Locale:        u.Locale,
+		Timezone:      u.Timezone,
+	}
+	var err error
+	if p.Roles, err = loadUserRoles(db.DB, u.ID); err != nil {
+		return p, err
+	}
+	p.Groups, err = queryStrings(db.DB, `SELECT g.name FROM groups g
+		JOIN user_groups ug ON ug.group_id = g.id
+		WHERE ug.user_id = $1 ORDER BY g.name`, u.ID)
+	return p, err
+}
+
+// GetMeHandler returns the caller's profile together with their effective
+// roles and groups.
+func GetMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims := r.Context().Value("userClaims").(*jwt.Claims)
+
+	user, err := loadUser(db.DB, claims.Subject, false)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	p, err := loadProfile(user)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(p)
+}
+
+// profileUpdate is the body of PATCH /me. Absent fields are left alone; an
+// empty string clears display name, locale or timezone. Changing the email
+// address also requires the current password.
+type profileUpdate struct {
+	Email           *string `json:"email"`
+	DisplayName     *string `json:"displayName"`
+	Locale          *string `json:"locale"`
+	Timezone        *string `json:"timezone"`
+	CurrentPassword string  `json:"currentPassword"`
+}
+
+// validate checks each present field and normalizes the display name.
+func (u *profileUpdate) validate() string {
+	if u.Email != nil && !validEmail(*u.Email) {
+		return "A valid email address is required"
+	}
+	if u.Email != nil && u.CurrentPassword == "" {
+		return "Current password is required to change the email address"
+	}
+	if u.DisplayName != nil {
+		name := strings.TrimSpace(*u.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return "Display name must be at most " + strconv.Itoa(maxDisplayNameLength) + " characters"
+		}
+		if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			return "Display name must not contain control characters"
+		}
+		u.DisplayName = &name
+	}
+	if u.Locale != nil && *u.Locale != "" && !localePattern.MatchString(*u.Locale) {
+		return "Locale must be a language tag such as en-GB"
+	}
+	if u.Timezone != nil && *u.Timezone != "" {
+		if _, err := time.LoadLocation(*u.Timezone); err != nil || *u.Timezone == "Local" {
+			return "Timezone must be an IANA time zone such as Europe/London"
+		}
+	}
+	return ""
+}
+
+// UpdateMeHandler updates the caller's profile. A new email address is not
+// used until the user follows the verification link mailed to it; until then
+// it is reported as pendingEmail.
+func UpdateMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims := r.Context().Value("userClaims").(*jwt.Claims)
+
+	var update profileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if msg := update.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	// A stolen access token alone must not be enough to move the account to
+	// another mailbox, from where the password could be reset.
+	if update.Email != nil {
+		var userID int
+		var storedPassword string
+		err := db.DB.QueryRow("SELECT id, password FROM users WHERE username = $1 AND deleted_at IS NULL", claims.Subject).
+			Scan(&userID, &storedPassword)
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(update.CurrentPassword)); err != nil {
+			http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	user, err := loadUser(tx, claims.Subject, true)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if update.DisplayName != nil {
+		user.DisplayName = *update.DisplayName
+	}
+	if update.Locale != nil {
+		user.Locale = *update.Locale
+	}
+	if update.Timezone != nil {
+		user.Timezone = *update.Timezone
+	}
+
+	// Asking for the current address back cancels a pending change.
+	var verificationToken string
+	if update.Email != nil {
+		switch newEmail := *update.Email; {
+		case strings.EqualFold(newEmail, user.Email):
+			user.PendingEmail = ""
+		case !strings.EqualFold(newEmail, user.PendingEmail):
+			var taken bool
+			var lastSent sql.NullTime
+			err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = LOWER($1)),
+				(SELECT MAX(created_at) FROM email_verification_tokens WHERE user_id = $2)`,
+				newEmail, user.ID).Scan(&taken, &lastSent)
+			if err != nil {
+				log.Printf("Database error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if taken {
+				http.Error(w, "Email address is already in use", http.StatusConflict)
+				return
+			}
+			if verificationThrottled(w, lastSent) {
+				return
+			}
+			if verificationToken, err = createVerificationToken(tx, user.ID, newEmail); err != nil {
+				log.Printf("Database error: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			user.PendingEmail = newEmail
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE users SET display_name = $1, locale = $2, timezone = $3, pending_email = NULLIF($4, '')
+		WHERE id = $5`, user.DisplayName, user.Locale, user.Timezone, user.PendingEmail, user.ID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if verificationToken != "" {
+		if err := mailVerification(user.Username, user.PendingEmail, verificationToken); err != nil {
+			log.Printf("Error sending verification email to %s: %v", user.Username, err)
+		}
+	}
+
+	p, err := loadProfile(user)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
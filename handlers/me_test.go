@@ -0,0 +1,150 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var userColumns = []string{"id", "username", "email", "email_verified", "pending_email", "display_name", "locale", "timezone"}
+
+func expectUser(mock sqlmock.Sqlmock, forUpdate bool) {
+	query := `SELECT id, username, COALESCE\(email, ''\)`
+	if forUpdate {
+		query += `.* FOR UPDATE`
+	}
+	mock.ExpectQuery(query).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(1, "alice", "alice@example.com", true, "", "Alice", "en-GB", "Europe/London"))
+}
+
+func expectRolesAndGroups(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT r.name FROM roles r").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("employer").AddRow("jobseeker"))
+	mock.ExpectQuery("SELECT g.name FROM groups g").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("acme"))
+}
+
+func TestGetMeHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectUser(mock, false)
+	expectRolesAndGroups(mock)
+
+	rec := httptest.NewRecorder()
+	handlers.GetMeHandler(rec, withClaims(httptest.NewRequest("GET", "/me", nil), userClaims("alice")))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":1,"username":"alice","email":"alice@example.com","emailVerified":true,
+		"displayName":"Alice","locale":"en-GB","timezone":"Europe/London",
+		"roles":["employer","jobseeker"],"groups":["acme"]}`, rec.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMeHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	expectUser(mock, true)
+	mock.ExpectExec("UPDATE users SET display_name = \\$1, locale = \\$2, timezone = \\$3").
+		WithArgs("Alice Smith", "fr-FR", "", "", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectRolesAndGroups(mock)
+
+	req := withClaims(jsonRequest("PATCH", "/me", `{"displayName":"  Alice Smith ","locale":"fr-FR","timezone":""}`),
+		userClaims("alice"))
+	rec := httptest.NewRecorder()
+	handlers.UpdateMeHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"displayName":"Alice Smith"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// A new email address stays pending until verified.
+func TestUpdateMeHandler_Email(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	expectCurrentPassword(t, mock, "my password")
+	mock.ExpectBegin()
+	expectUser(mock, true)
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("alice@new.example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "max"}).AddRow(false, nil))
+	mock.ExpectExec("INSERT INTO email_verification_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, "alice@new.example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET display_name").
+		WithArgs("Alice", "en-GB", "Europe/London", "alice@new.example.com", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectRolesAndGroups(mock)
+
+	req := withClaims(jsonRequest("PATCH", "/me", `{"email":"alice@new.example.com","currentPassword":"my password"}`), userClaims("alice"))
+	rec := httptest.NewRecorder()
+	handlers.UpdateMeHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"email":"alice@example.com"`)
+	assert.Contains(t, rec.Body.String(), `"pendingEmail":"alice@new.example.com"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, box.sent, 1)
+	assert.Equal(t, "alice@new.example.com", box.sent[0].To)
+}
+
+func TestUpdateMeHandler_EmailTaken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	expectCurrentPassword(t, mock, "my password")
+	mock.ExpectBegin()
+	expectUser(mock, true)
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("bob@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "max"}).AddRow(true, nil))
+	mock.ExpectRollback()
+
+	req := withClaims(jsonRequest("PATCH", "/me", `{"email":"bob@example.com","currentPassword":"my password"}`), userClaims("alice"))
+	rec := httptest.NewRecorder()
+	handlers.UpdateMeHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMeHandler_EmailWrongPassword(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectCurrentPassword(t, mock, "my password")
+
+	req := withClaims(jsonRequest("PATCH", "/me", `{"email":"alice@new.example.com","currentPassword":"guess"}`),
+		userClaims("alice"))
+	rec := httptest.NewRecorder()
+	handlers.UpdateMeHandler(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMeHandler_Validation(t *testing.T) {
+	for _, body := range []string{
+		`{"email":"not-an-email","currentPassword":"my password"}`,
+		`{"email":"alice@new.example.com"}`,
+		`{"locale":"english please"}`,
+		`{"timezone":"Mars/Olympus_Mons"}`,
+		`{"timezone":"Local"}`,
+		`{"displayName":"bell\u0007"}`,
+	} {
+		req := withClaims(jsonRequest("PATCH", "/me", body), userClaims("alice"))
+		rec := httptest.NewRecorder()
+		handlers.UpdateMeHandler(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"auth-service/audit"
+	"auth-service/db"
+	"auth-service/mail"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordBytes is bcrypt's input limit; longer passwords would be
+// silently truncated.
+const maxPasswordBytes = 72
+
+// minPasswordLength is the shortest acceptable password, read from
+// PASSWORD_MIN_LENGTH. It defaults to 8.
+func minPasswordLength() int {
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		return n
+	}
+	return 8
+}
+
+// validatePassword applies the password policy.
+func validatePassword(password string) error {
+	if n := len([]rune(password)); n < minPasswordLength() {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength())
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+	return nil
+}
+
+// passwordResetTTL is how long a reset token stays usable, read from
+// PASSWORD_RESET_TTL as a Go duration. It defaults to 1 hour.
+func passwordResetTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return time.Hour
+}
+
+// passwordResetInterval is the minimum time between reset mails to one
+// account, read from PASSWORD_RESET_RESEND_INTERVAL as a Go duration. It
+// defaults to 1 minute.
+func passwordResetInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_RESEND_INTERVAL")); err == nil && d >= 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// passwordResetLink builds the link mailed to the user. PASSWORD_RESET_URL is
+// the front-end page that collects the new password; without it the bare
+// token is sent.
+func passwordResetLink(token string) string {
+	base := os.Getenv("PASSWORD_RESET_URL")
+	if base == "" {
+		return token
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+type forgotPasswordRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// ForgotPasswordHandler mails a single-use reset token to the address on
+// file for the given email or username. The response is the same whether or
+// not the account exists, so it cannot be used to discover accounts. Requests
+// for an account that was mailed less than passwordResetInterval ago are
+// ignored, with the same response, as are requests for an account whose
+// address was never verified.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if (req.Email == "") == (req.Username == "") {
+		http.Error(w, "Exactly one of email and username is required", http.StatusBadRequest)
+		return
+	}
+
+	var userID int
+	var username, email string
+	var lastSent sql.NullTime
+	var verified bool
+	err := db.DB.QueryRow(`SELECT u.id, u.username, u.email,
+		(SELECT MAX(created_at) FROM password_reset_tokens WHERE user_id = u.id), u.email_verified
+		FROM users u
+		WHERE (LOWER(u.email) = LOWER($1) OR u.username = $2) AND u.email IS NOT NULL AND u.deleted_at IS NULL`,
+		req.Email, req.Username).Scan(&userID, &username, &email, &lastSent, &verified)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil && !verified {
+		log.Printf("Password reset for %s requested for an unverified address; not sending", username)
+	} else if err == nil && lastSent.Valid && time.Since(lastSent.Time) < passwordResetInterval() {
+		log.Printf("Password reset for %s requested again too soon; not sending", username)
+	} else if err == nil {
+		if err := sendPasswordReset(userID, username, email); err != nil {
+			log.Printf("Error sending password reset for %s: %v", username, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(JSONResponse{"message": "If the account exists, a password reset link has been sent"})
+}
+
+// sendPasswordReset replaces the user's reset tokens with a new one and
+// mails it, so only the most recent link works.
+func sendPasswordReset(userID int, username, email string) error {
+	token, err := jwt.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	ttl := passwordResetTTL()
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		jwt.HashOpaqueToken(token), userID, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	if err := audit.Record(tx, audit.Event{Type: audit.PasswordResetRequested, Actor: username, Subject: username}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return mail.Send(mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password for %s.\n\n"+
+			"Use this to choose a new password within %s:\n\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.\n",
+			username, ttl, passwordResetLink(token)),
+	})
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+var errResetTokenInvalid = errors.New("reset token is invalid or expired")
+
+// ResetPasswordHandler sets a new password using a token from
+// ForgotPasswordHandler. The token and any other outstanding tokens for the
+// user are spent, and every existing session is revoked.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := resetPassword(jwt.HashOpaqueToken(req.Token), string(hashedPassword)); err != nil {
+		if err == errResetTokenInvalid {
+			http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Password has been reset"})
+}
+
+func resetPassword(tokenHash, hashedPassword string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the token row makes concurrent resets with the same token
+	// wait, and then find it spent.
+	var userID int
+	var username string
+	err = tx.QueryRow(`SELECT t.user_id, u.username FROM password_reset_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > NOW()
+		FOR UPDATE OF t`, tokenHash).Scan(&userID, &username)
+	if err == sql.ErrNoRows {
+		return errResetTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password = $1 WHERE id = $2", hashedPassword, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL",
+		userID); err != nil {
+		return err
+	}
+	if err := revokeAllSessions(tx, username); err != nil {
+		return err
+	}
+	if err := audit.Record(tx, audit.Event{Type: audit.PasswordReset, Actor: username, Subject: username}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// changePasswordRequest is the body of ChangePasswordHandler. RefreshToken,
+// when sent, identifies the caller's own refresh token family, which is kept.
+type changePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	RefreshToken    string `json:"refreshToken"`
+}
+
+// ChangePasswordHandler lets a signed-in user change their password. Every
+// other session is revoked: the token version is bumped and all refresh
+// tokens except the caller's family are revoked. The caller gets a new
+// access token, plus a new refresh token unless theirs was kept.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims := r.Context().Value("userClaims").(*jwt.Claims)
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "Current and new password are required", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		http.Error(w, "New password must differ from the current password", http.StatusBadRequest)
+		return
+	}
+
+	var userID int
+	var storedPassword string
+	err := db.DB.QueryRow("SELECT id, password FROM users WHERE username = $1 AND deleted_at IS NULL", claims.Subject).
+		Scan(&userID, &storedPassword)
+	if err == sql.ErrNoRows {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(req.CurrentPassword)); err != nil {
+		http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	response, err := changePassword(tx, claims, userID, string(hashedPassword), req.RefreshToken)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Error changing password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response["message"] = "Password changed; other sessions have been signed out"
+	json.NewEncoder(w).Encode(response)
+}
+
+// changePassword stores the new hash, signs out every other session and
+// returns a token response for the caller's session.
+func changePassword(tx *sql.Tx, claims *jwt.Claims, userID int, hashedPassword, refreshToken string) (JSONResponse, error) {
+	username := claims.Subject
+	var tokenVersion int
+	err := tx.QueryRow("UPDATE users SET password = $1, token_version = token_version + 1 WHERE id = $2 RETURNING token_version",
+		hashedPassword, userID).Scan(&tokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the caller's refresh token family if they told us which it is.
+	var keptFamily string
+	if refreshToken != "" {
+		err := tx.QueryRow(`SELECT family_id FROM refresh_tokens
+			WHERE token_hash = $1 AND username = $2 AND revoked_at IS NULL AND rotated_at IS NULL AND expires_at > NOW()`,
+			jwt.HashOpaqueToken(refreshToken), username).Scan(&keptFamily)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE username = $1 AND revoked_at IS NULL AND family_id <> $2",
+		username, keptFamily); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL",
+		userID); err != nil {
+		return nil, err
+	}
+	if err := audit.Record(tx, audit.Event{Type: audit.PasswordChanged, Actor: username, Subject: username}); err != nil {
+		return nil, err
+	}
+
+	// The bump above invalidated the caller's access token too, so mint a
+	// replacement for the same audience and DPoP key.
+	roles, err := loadUserRoles(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	audience := jwt.DefaultAudience()
+	if len(claims.Audience) > 0 {
+		audience = claims.Audience[0]
+	}
+	var dpopJKT string
+	if claims.Cnf != nil {
+		dpopJKT = claims.Cnf.JKT
+	}
+	token, err := jwt.GenerateUserToken(jwt.TokenParams{
+		Username:     username,
+		Roles:        roles,
+		Audience:     audience,
+		TokenVersion: tokenVersion,
+		DPoPJKT:      dpopJKT,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if keptFamily == "" {
+		refreshToken, err = issueRefreshToken(tx, refreshTokenParams{Username: username, Audience: audience, DPoPJKT: dpopJKT})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tokenResponse(token, refreshToken, dpopJKT), nil
+}
@@ -0,0 +1,261 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"auth-service/mail"
+	jwt "auth-service/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// outbox records the messages it is asked to send.
+type outbox struct {
+	sent []mail.Message
+}
+
+func (o *outbox) Send(msg mail.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+func useOutbox(t *testing.T) *outbox {
+	o := &outbox{}
+	mail.SetSender(o)
+	t.Cleanup(func() { mail.SetSender(mail.LogSender{}) })
+	return o
+}
+
+func jsonRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestForgotPasswordHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+	os.Setenv("PASSWORD_RESET_URL", "https://jobs.example.com/reset")
+	defer os.Unsetenv("PASSWORD_RESET_URL")
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.email,\\s+\\(SELECT MAX\\(created_at\\) FROM password_reset_tokens").
+		WithArgs("Alice@Example.com", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "max", "email_verified"}).
+			AddRow(1, "alice", "alice@example.com", time.Now().Add(-time.Hour), true))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM password_reset_tokens WHERE user_id = \\$1").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("password.reset_requested", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.ForgotPasswordHandler(rec, jsonRequest("POST", "/password/forgot", `{"email":"Alice@Example.com"}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, box.sent, 1)
+	assert.Equal(t, "alice@example.com", box.sent[0].To)
+	link := regexp.MustCompile(`https://jobs\.example\.com/reset\?token=[A-Za-z0-9_-]{43}`)
+	assert.Regexp(t, link, box.sent[0].Body)
+}
+
+// Unknown accounts get the same response and no mail.
+func TestForgotPasswordHandler_UnknownAccount(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs("", "nobody").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "max", "email_verified"}))
+
+	rec := httptest.NewRecorder()
+	handlers.ForgotPasswordHandler(rec, jsonRequest("POST", "/password/forgot", `{"username":"nobody"}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, box.sent)
+}
+
+// A repeated request within the resend interval gets the same response but
+// sends nothing.
+func TestForgotPasswordHandler_Throttled(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs("", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "max", "email_verified"}).
+			AddRow(1, "alice", "alice@example.com", time.Now().Add(-10*time.Second), true))
+
+	rec := httptest.NewRecorder()
+	handlers.ForgotPasswordHandler(rec, jsonRequest("POST", "/password/forgot", `{"username":"alice"}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, box.sent)
+}
+
+// An address that was never verified gets the same response but no mail, so
+// a mistyped or claimed address cannot receive a reset link.
+func TestForgotPasswordHandler_UnverifiedEmail(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs("", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "max", "email_verified"}).
+			AddRow(1, "alice", "alice@example.com", nil, false))
+
+	rec := httptest.NewRecorder()
+	handlers.ForgotPasswordHandler(rec, jsonRequest("POST", "/password/forgot", `{"username":"alice"}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, box.sent)
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT t.user_id, u.username FROM password_reset_tokens t").
+		WithArgs(jwt.HashOpaqueToken("reset-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username"}).AddRow(1, "alice"))
+	mock.ExpectExec("UPDATE users SET password = \\$1 WHERE id = \\$2").
+		WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE password_reset_tokens SET used_at = NOW\\(\\)").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET token_version = token_version \\+ 1 WHERE username = \\$1").
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\)").
+		WithArgs("alice").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("password.reset", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.ResetPasswordHandler(rec, jsonRequest("POST", "/password/reset",
+		`{"token":"reset-token","password":"correct horse"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Used, expired and unknown tokens look the same.
+func TestResetPasswordHandler_InvalidToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT t.user_id, u.username FROM password_reset_tokens t").
+		WithArgs(jwt.HashOpaqueToken("spent")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username"}))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	handlers.ResetPasswordHandler(rec, jsonRequest("POST", "/password/reset",
+		`{"token":"spent","password":"correct horse"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Invalid or expired reset token")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetPasswordHandler_WeakPassword(t *testing.T) {
+	_, cleanup := setupMockDB()
+	defer cleanup()
+
+	rec := httptest.NewRecorder()
+	handlers.ResetPasswordHandler(rec, jsonRequest("POST", "/password/reset", `{"token":"t","password":"short"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "at least 8 characters")
+}
+
+func expectCurrentPassword(t *testing.T, mock sqlmock.Sqlmock, password string) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	mock.ExpectQuery(`SELECT id, password FROM users WHERE username = \$1`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).AddRow(1, string(hash)))
+}
+
+// The caller's refresh token family survives; everything else is revoked.
+func TestChangePasswordHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	expectCurrentPassword(t, mock, "old password")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE users SET password = \\$1, token_version = token_version \\+ 1 WHERE id = \\$2 RETURNING token_version").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(4))
+	mock.ExpectQuery("SELECT family_id FROM refresh_tokens").
+		WithArgs(jwt.HashOpaqueToken("my-refresh"), "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"family_id"}).AddRow("fam-1"))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE username = \\$1 AND revoked_at IS NULL AND family_id <> \\$2").
+		WithArgs("alice", "fam-1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE password_reset_tokens SET used_at = NOW\\(\\)").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("password.changed", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT r.name FROM roles r").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jobseeker"))
+	mock.ExpectCommit()
+
+	req := withClaims(jsonRequest("POST", "/me/password",
+		`{"currentPassword":"old password","newPassword":"new password","refreshToken":"my-refresh"}`), userClaims("alice"))
+	rec := httptest.NewRecorder()
+	handlers.ChangePasswordHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "my-refresh", response["refreshToken"])
+	claims, _, err := jwt.ValidateToken(response["token"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, 4, claims.TokenVersion)
+}
+
+func TestChangePasswordHandler_WrongCurrentPassword(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectCurrentPassword(t, mock, "old password")
+
+	req := withClaims(jsonRequest("POST", "/me/password",
+		`{"currentPassword":"guess","newPassword":"new password"}`), userClaims("alice"))
+	rec := httptest.NewRecorder()
+	handlers.ChangePasswordHandler(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePasswordHandler_Policy(t *testing.T) {
+	for _, body := range []string{
+		`{"currentPassword":"old password","newPassword":"short"}`,
+		`{"currentPassword":"old password","newPassword":"old password"}`,
+	} {
+		req := withClaims(jsonRequest("POST", "/me/password", body), userClaims("alice"))
+		rec := httptest.NewRecorder()
+		handlers.ChangePasswordHandler(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"auth-service/authz"
+	"auth-service/db"
+	"auth-service/models"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// decodePermission reads and validates a permission from the request body.
+func decodePermission(w http.ResponseWriter, r *http.Request) (models.Permissions, bool) {
+	var permission models.Permissions
+	if err := json.NewDecoder(r.Body).Decode(&permission); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return permission, false
+	}
+	if !permissionPart(permission.Resource) || !permissionPart(permission.Action) ||
+		(permission.Instance != "" && !permissionPart(permission.Instance)) {
+		http.Error(w, "Resource, instance and action must be '*' or 1-64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return permission, false
+	}
+	return permission, true
+}
+
+// permissionPart reports whether s is a valid resource, instance or action.
+func permissionPart(s string) bool {
+	return s == authz.Wildcard || namePattern.MatchString(s)
+}
+
+// scanPermissions reads id, resource, instance, action and description rows.
+func scanPermissions(rows *sql.Rows) ([]models.Permissions, error) {
+	defer rows.Close()
+	permissions := []models.Permissions{}
+	for rows.Next() {
+		var p models.Permissions
+		if err := rows.Scan(&p.ID, &p.Resource, &p.Instance, &p.Action, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+// ListPermissionsHandler returns every permission.
+func ListPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	rows, err := db.DB.Query("SELECT id, resource, instance, action, description FROM permissions ORDER BY id")
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	permissions, err := scanPermissions(rows)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"permissions": permissions})
+}
+
+// GetPermissionHandler returns a single permission.
+func GetPermissionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid permission id", http.StatusBadRequest)
+		return
+	}
+
+	permission := models.Permissions{ID: id}
+	err := db.DB.QueryRow("SELECT resource, instance, action, description FROM permissions WHERE id = $1", id).
+		Scan(&permission.Resource, &permission.Instance, &permission.Action, &permission.Description)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Permission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(permission)
+}
+
+// CreatePermissionHandler adds a permission. Each (resource, instance,
+// action) combination exists at most once.
+func CreatePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	permission, ok := decodePermission(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DB.QueryRow("INSERT INTO permissions (resource, instance, action, description) VALUES ($1, $2, $3, $4) RETURNING id",
+		permission.Resource, permission.Instance, permission.Action, permission.Description).Scan(&permission.ID)
+	if isUniqueViolation(err) {
+		http.Error(w, "That permission already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Permission %s created", authz.Permission{Resource: permission.Resource, Instance: permission.Instance, Action: permission.Action})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(permission)
+}
+
+// UpdatePermissionHandler changes a permission's resource, instance, action
+// or description. Roles holding it keep it.
+func UpdatePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid permission id", http.StatusBadRequest)
+		return
+	}
+	permission, ok := decodePermission(w, r)
+	if !ok {
+		return
+	}
+	permission.ID = id
+
+	res, err := db.DB.Exec("UPDATE permissions SET resource = $1, instance = $2, action = $3, description = $4 WHERE id = $5",
+		permission.Resource, permission.Instance, permission.Action, permission.Description, id)
+	if isUniqueViolation(err) {
+		http.Error(w, "That permission already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Permission not found", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(permission)
+}
+
+// DeletePermissionHandler removes a permission and every grant of it.
+func DeletePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid permission id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM permissions WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Permission not found", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Permission deleted"})
+}
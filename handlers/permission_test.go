@@ -0,0 +1,85 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePermissionHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO permissions").
+		WithArgs("job", "", "publish", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	req := httptest.NewRequest("POST", "/admin/permissions", strings.NewReader(`{"resource":"job","action":"publish"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreatePermissionHandler(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatePermissionHandler_Duplicate(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO permissions").
+		WithArgs("job", "", "publish", "").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	req := httptest.NewRequest("POST", "/admin/permissions", strings.NewReader(`{"resource":"job","action":"publish"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreatePermissionHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestCreatePermissionHandler_MissingAction(t *testing.T) {
+	req := httptest.NewRequest("POST", "/admin/permissions", strings.NewReader(`{"resource":"job"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreatePermissionHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDeletePermissionHandler_NotFound(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectExec(`DELETE FROM permissions WHERE id = \$1`).
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/permissions/9", nil), map[string]string{"id": "9"})
+	rec := httptest.NewRecorder()
+	handlers.DeletePermissionHandler(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCreatePermissionHandler_Patterns(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO permissions").
+		WithArgs("job", "1234", "*", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	req := httptest.NewRequest("POST", "/admin/permissions", strings.NewReader(`{"resource":"job","instance":"1234","action":"*"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreatePermissionHandler(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	req = httptest.NewRequest("POST", "/admin/permissions", strings.NewReader(`{"resource":"job","instance":"12:34","action":"edit"}`))
+	rec = httptest.NewRecorder()
+	handlers.CreatePermissionHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"auth-service/rebac"
+	jwt "auth-service/utils"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// relationRequest is a tuple in its string form, e.g. object "job:42",
+// relation "editor", subject "user:alice" or "company:7#recruiter". Check
+// requests may name the user by access token instead of subject.
+type relationRequest struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+	Token    string `json:"token"`
+}
+
+// decodeTuple reads and validates a tuple from the request body, writing a
+// 400 and returning false when it is not acceptable.
+func decodeTuple(w http.ResponseWriter, r *http.Request) (rebac.Tuple, bool) {
+	var req relationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return rebac.Tuple{}, false
+	}
+	tuple, err := parseTuple(req)
+	if err == nil {
+		err = rebac.Default().Schema.ValidateTuple(tuple)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return rebac.Tuple{}, false
+	}
+	return tuple, true
+}
+
+func parseTuple(req relationRequest) (rebac.Tuple, error) {
+	object, err := rebac.ParseObject(req.Object)
+	if err != nil {
+		return rebac.Tuple{}, err
+	}
+	if req.Relation == "" {
+		return rebac.Tuple{}, errors.New("relation is required")
+	}
+	subject, err := rebac.ParseSubject(req.Subject)
+	if err != nil {
+		return rebac.Tuple{}, err
+	}
+	return rebac.Tuple{Object: object, Relation: req.Relation, Subject: subject}, nil
+}
+
+// WriteRelationHandler lets a client service record a relationship, e.g.
+// that job 42 belongs to company 7 when the job is created. Writing an
+// existing tuple is a no-op.
+func WriteRelationHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticateClient(w, r); !ok {
+		return
+	}
+	tuple, ok := decodeTuple(w, r)
+	if !ok {
+		return
+	}
+	if err := rebac.Default().Store.Write(tuple); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(JSONResponse{"tuple": tuple.String()})
+}
+
+// DeleteRelationHandler removes a relationship written by WriteRelationHandler.
+func DeleteRelationHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticateClient(w, r); !ok {
+		return
+	}
+	tuple, ok := decodeTuple(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := rebac.Default().Store.Delete(tuple)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "Relation not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckRelationHandler answers whether a subject has a relation to an object,
+// following the schema's userset rewrites, e.g. whether user:alice is an
+// editor of job:42 because she recruits for the company that owns it. The
+// user may be given as a subject or as one of their access tokens.
+func CheckRelationHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticateClient(w, r); !ok {
+		return
+	}
+
+	var req relationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if (req.Subject == "") == (req.Token == "") {
+		http.Error(w, "Exactly one of subject and token is required", http.StatusBadRequest)
+		return
+	}
+	object, err := rebac.ParseObject(req.Object)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Relation == "" {
+		http.Error(w, "relation is required", http.StatusBadRequest)
+		return
+	}
+
+	subject, reason := rebac.Subject{}, ""
+	if req.Token != "" {
+		opts := jwt.DefaultValidationOptions()
+		opts.Audiences = nil
+		if claims, _, err := jwt.ValidateTokenWithOptions(req.Token, opts); err != nil {
+			reason = "token is not active"
+		} else if claims.Restricted() {
+			reason = "token is scoped or delegated"
+		} else {
+			subject.Object = rebac.Object{Type: "user", ID: claims.Subject}
+		}
+	} else if subject, err = rebac.ParseSubject(req.Subject); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	allowed := false
+	if reason == "" {
+		allowed, err = rebac.Default().Check(object, req.Relation, subject)
+		if errors.Is(err, rebac.ErrMaxDepth) {
+			reason = "relationship chain is too deep"
+		} else if err != nil {
+			log.Printf("Error checking %s#%s for %s: %v", object, req.Relation, subject, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := JSONResponse{
+		"object":   object.String(),
+		"relation": req.Relation,
+		"subject":  "",
+		"allowed":  allowed,
+		"decision": "deny",
+	}
+	if subject.ID != "" {
+		response["subject"] = subject.String()
+	}
+	if allowed {
+		response["decision"] = "allow"
+	} else if reason != "" {
+		response["reason"] = reason
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	jwt "auth-service/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func relationRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("gateway", "s3cret")
+	return req
+}
+
+func expectSubjects(mock sqlmock.Sqlmock, object, id, relation string, subjects ...[3]string) {
+	rows := sqlmock.NewRows([]string{"subject_type", "subject_id", "subject_relation"})
+	for _, s := range subjects {
+		rows.AddRow(s[0], s[1], s[2])
+	}
+	mock.ExpectQuery("SELECT subject_type, subject_id, subject_relation FROM relation_tuples").
+		WithArgs(object, id, relation).
+		WillReturnRows(rows)
+}
+
+func TestWriteRelationHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	mock.ExpectExec("INSERT INTO relation_tuples").
+		WithArgs("job", "42", "company", "company", "7", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	handlers.WriteRelationHandler(rec, relationRequest("POST", "/relations",
+		`{"object":"job:42","relation":"company","subject":"company:7"}`))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"tuple":"job:42#company@company:7"}`, rec.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteRelationHandler_UnknownRelation(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.WriteRelationHandler(rec, relationRequest("POST", "/relations",
+		`{"object":"job:42","relation":"publisher","subject":"user:alice"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `job has no relation "publisher"`)
+}
+
+func TestDeleteRelationHandler_NotFound(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	mock.ExpectExec("DELETE FROM relation_tuples").
+		WithArgs("company", "7", "recruiter", "user", "alice", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rec := httptest.NewRecorder()
+	handlers.DeleteRelationHandler(rec, relationRequest("DELETE", "/relations",
+		`{"object":"company:7","relation":"recruiter","subject":"user:alice"}`))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// Alice edits job 42 because she recruits for company 7, which owns it.
+func TestCheckRelationHandler_Allow(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+	expectSubjects(mock, "job", "42", "editor")
+	expectSubjects(mock, "job", "42", "owner")
+	expectSubjects(mock, "job", "42", "company", [3]string{"company", "7", ""})
+	expectSubjects(mock, "company", "7", "owner")
+	expectSubjects(mock, "job", "42", "company", [3]string{"company", "7", ""})
+	expectSubjects(mock, "company", "7", "recruiter", [3]string{"user", "alice", ""})
+
+	rec := httptest.NewRecorder()
+	handlers.CheckRelationHandler(rec, relationRequest("POST", "/relations/check",
+		`{"object":"job:42","relation":"editor","subject":"user:alice"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"object":"job:42","relation":"editor","subject":"user:alice","allowed":true,"decision":"allow"}`,
+		rec.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckRelationHandler_InactiveToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	expectClient(t, mock)
+
+	rec := httptest.NewRecorder()
+	handlers.CheckRelationHandler(rec, relationRequest("POST", "/relations/check",
+		`{"object":"job:42","relation":"editor","token":"not-a-token"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"object":"job:42","relation":"editor","subject":"","allowed":false,"decision":"deny",
+		"reason":"token is not active"}`, rec.Body.String())
+}
+
+func TestCheckRelationHandler_ScopedToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	expectClient(t, mock)
+
+	claims := &jwt.Claims{Username: "alice", Scope: "jobs:read"}
+	claims.Subject = "alice"
+	token, err := jwt.IssueToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handlers.CheckRelationHandler(rec, relationRequest("POST", "/relations/check",
+		`{"object":"job:42","relation":"editor","token":"`+token+`"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"object":"job:42","relation":"editor","subject":"","allowed":false,"decision":"deny",
+		"reason":"token is scoped or delegated"}`, rec.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
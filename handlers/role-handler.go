@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"auth-service/authz"
+	"auth-service/db"
+	"auth-service/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// decodeRole reads and validates a role from the request body. parentSet
+// reports whether the body has a parentId at all, so that an update can tell
+// an omitted parent from one cleared with null.
+func decodeRole(w http.ResponseWriter, r *http.Request) (role models.Roles, parentSet bool, ok bool) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return role, false, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &role); err != nil || json.Unmarshal(raw, &fields) != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return role, false, false
+	}
+	if !namePattern.MatchString(role.Name) {
+		http.Error(w, "Role name must be 1-64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return role, false, false
+	}
+	_, parentSet = fields["parentId"]
+	return role, parentSet, true
+}
+
+// ListRolesHandler returns every role.
+func ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	rows, err := db.DB.Query("SELECT id, name, description, parent_id FROM roles ORDER BY id")
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	roles := []models.Roles{}
+	for rows.Next() {
+		var role models.Roles
+		if err := rows.Scan(&role.ID, &role.Name, &role.Descriptions, &role.ParentID); err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"roles": roles})
+}
+
+// GetRoleHandler returns a single role.
+func GetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+
+	role := models.Roles{ID: id}
+	err := db.DB.QueryRow("SELECT name, description, parent_id FROM roles WHERE id = $1", id).
+		Scan(&role.Name, &role.Descriptions, &role.ParentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(role)
+}
+
+// CreateRoleHandler adds a role. Names are unique.
+func CreateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	role, _, ok := decodeRole(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.DB.QueryRow("INSERT INTO roles (name, description, parent_id) VALUES ($1, $2, $3) RETURNING id",
+		role.Name, role.Descriptions, role.ParentID).Scan(&role.ID)
+	if isUniqueViolation(err) {
+		http.Error(w, "A role with that name already exists", http.StatusConflict)
+		return
+	}
+	if isForeignKeyViolation(err) {
+		http.Error(w, "Parent role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Role %s created", role.Name)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(role)
+}
+
+// UpdateRoleHandler renames a role, changes its description or moves it in
+// the hierarchy. The parent is only changed when parentId is given; null
+// makes the role a root. A parent that would make the role its own ancestor
+// is rejected.
+func UpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+	role, parentSet, ok := decodeRole(w, r)
+	if !ok {
+		return
+	}
+	role.ID = id
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if !parentSet {
+		err := tx.QueryRow("SELECT parent_id FROM roles WHERE id = $1 FOR UPDATE", id).Scan(&role.ParentID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Role not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	} else if role.ParentID != nil {
+		// Serialize hierarchy changes so that two concurrent updates cannot
+		// each pass the cycle check and together form a cycle.
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('role_hierarchy'))"); err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		cycle, err := createsCycle(tx, id, *role.ParentID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if cycle {
+			http.Error(w, "A role cannot inherit from itself or one of its descendants", http.StatusConflict)
+			return
+		}
+	}
+
+	res, err := tx.Exec("UPDATE roles SET name = $1, description = $2, parent_id = $3 WHERE id = $4",
+		role.Name, role.Descriptions, role.ParentID, id)
+	if isUniqueViolation(err) {
+		http.Error(w, "A role with that name already exists", http.StatusConflict)
+		return
+	}
+	if isForeignKeyViolation(err) {
+		http.Error(w, "Parent role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(role)
+}
+
+// createsCycle reports whether making parentID the parent of roleID would
+// create a cycle, i.e. whether roleID is parentID or one of its ancestors.
+func createsCycle(q queryer, roleID, parentID int) (bool, error) {
+	ancestors, err := q.Query(`WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM roles WHERE id = $1
+			UNION
+			SELECT r.id, r.parent_id FROM roles r JOIN ancestors a ON r.id = a.parent_id
+		)
+		SELECT id FROM ancestors`, parentID)
+	if err != nil {
+		return false, err
+	}
+	defer ancestors.Close()
+	for ancestors.Next() {
+		var id int
+		if err := ancestors.Scan(&id); err != nil {
+			return false, err
+		}
+		if id == roleID {
+			return true, nil
+		}
+	}
+	return false, ancestors.Err()
+}
+
+// DeleteRoleHandler removes a role. A role that is still held by users or
+// groups is only deleted when ?force=true is given, in which case those
+// assignments are removed with it.
+func DeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var users, groups int
+	err = tx.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM user_roles WHERE role_id = $1),
+		(SELECT COUNT(*) FROM group_roles WHERE role_id = $1)`, id).Scan(&users, &groups)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if (users > 0 || groups > 0) && !force {
+		http.Error(w, fmt.Sprintf("Role is assigned to %d users and %d groups; use force=true to delete it anyway", users, groups),
+			http.StatusConflict)
+		return
+	}
+
+	// user_roles, group_roles and role_permissions rows go with the role
+	// through ON DELETE CASCADE.
+	res, err := tx.Exec("DELETE FROM roles WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authz.InvalidateAll()
+	log.Printf("Role %d deleted (%d user and %d group assignments removed)", id, users, groups)
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Role deleted"})
+}
+
+// ListRolePermissionsHandler returns the permissions granted to a role
+// directly and those it inherits from its ancestors.
+func ListRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := db.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM roles WHERE id = $1)", id).Scan(&exists); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := db.DB.Query(`SELECT p.id, p.resource, p.instance, p.action, p.description FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		WHERE rp.role_id = $1 ORDER BY p.id`, id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	permissions, err := scanPermissions(rows)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	inherited, err := inheritedPermissions(id)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"roleId": id, "permissions": permissions, "inherited": inherited})
+}
+
+// inheritedPermission is a permission a role holds through an ancestor.
+type inheritedPermission struct {
+	models.Permissions
+	InheritedFrom string `json:"inheritedFrom"`
+}
+
+// inheritedPermissions lists the permissions of every ancestor of a role,
+// nearest ancestor first.
+func inheritedPermissions(roleID int) ([]inheritedPermission, error) {
+	rows, err := db.DB.Query(`WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM roles WHERE id = $1 AND parent_id IS NOT NULL
+			UNION
+			SELECT r.parent_id, a.depth + 1 FROM roles r JOIN ancestors a ON r.id = a.id
+			WHERE r.parent_id IS NOT NULL AND a.depth < $2
+		)
+		SELECT p.id, p.resource, p.instance, p.action, p.description, r.name
+		FROM ancestors a
+		JOIN roles r ON r.id = a.id
+		JOIN role_permissions rp ON rp.role_id = a.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE a.id <> $1
+		ORDER BY a.depth, p.id`, roleID, authz.MaxRoleDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inherited := []inheritedPermission{}
+	for rows.Next() {
+		var p inheritedPermission
+		if err := rows.Scan(&p.ID, &p.Resource, &p.Instance, &p.Action, &p.Description, &p.InheritedFrom); err != nil {
+			return nil, err
+		}
+		inherited = append(inherited, p)
+	}
+	return inherited, rows.Err()
+}
+
+// AddRolePermissionHandler grants a permission to a role. Granting a
+// permission the role already has is not an error.
+func AddRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+	var grant models.RolePermissions
+	if err := json.NewDecoder(r.Body).Decode(&grant); err != nil || grant.PermissionID <= 0 {
+		http.Error(w, "permissionId is required", http.StatusBadRequest)
+		return
+	}
+	grant.RoleID = id
+
+	_, err := db.DB.Exec(`INSERT INTO role_permissions (role_id, permission_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, grant.RoleID, grant.PermissionID)
+	if isForeignKeyViolation(err) {
+		http.Error(w, "Role or permission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authz.InvalidateAll()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// RemoveRolePermissionHandler takes a permission away from a role.
+func RemoveRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+	permissionID, ok := pathID(r, "permissionId")
+	if !ok {
+		http.Error(w, "Invalid permission id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM role_permissions WHERE role_id = $1 AND permission_id = $2", id, permissionID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Role does not have that permission", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Permission removed from role"})
+}
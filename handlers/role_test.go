@@ -0,0 +1,201 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRoleHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO roles").
+		WithArgs("recruiter", "Hiring staff", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	req := httptest.NewRequest("POST", "/admin/roles", strings.NewReader(`{"name":"recruiter","description":"Hiring staff"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreateRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id":4,"name":"recruiter","description":"Hiring staff","parentId":null}`, rec.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRoleHandler_InvalidName(t *testing.T) {
+	req := httptest.NewRequest("POST", "/admin/roles", strings.NewReader(`{"name":"has space"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreateRoleHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreateRoleHandler_Duplicate(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("INSERT INTO roles").
+		WithArgs("admin", "", nil).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	req := httptest.NewRequest("POST", "/admin/roles", strings.NewReader(`{"name":"admin"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreateRoleHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestDeleteRoleHandler_StillAssigned(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"users", "groups"}).AddRow(2, 0))
+	mock.ExpectRollback()
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/roles/3", nil), map[string]string{"id": "3"})
+	rec := httptest.NewRecorder()
+	handlers.DeleteRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRoleHandler_Force(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"users", "groups"}).AddRow(2, 1))
+	mock.ExpectExec(`DELETE FROM roles WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/roles/3?force=true", nil), map[string]string{"id": "3"})
+	rec := httptest.NewRecorder()
+	handlers.DeleteRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddRolePermissionHandler_UnknownPermission(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectExec("INSERT INTO role_permissions").
+		WithArgs(3, 99).
+		WillReturnError(&pq.Error{Code: "23503"})
+
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/roles/3/permissions", strings.NewReader(`{"permissionId":99}`)),
+		map[string]string{"id": "3"})
+	rec := httptest.NewRecorder()
+	handlers.AddRolePermissionHandler(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestListRolePermissionsHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT p.id, p.resource, p.instance, p.action, p.description FROM permissions p").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "resource", "instance", "action", "description"}).
+			AddRow(1, "job", "", "publish", "Publish job ads"))
+	mock.ExpectQuery("WITH RECURSIVE ancestors").
+		WithArgs(3, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "resource", "instance", "action", "description", "name"}).
+			AddRow(2, "job", "", "view", "", "user"))
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/admin/roles/3/permissions", nil), map[string]string{"id": "3"})
+	rec := httptest.NewRecorder()
+	handlers.ListRolePermissionsHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"roleId":3,
+		"permissions":[{"id":1,"resource":"job","action":"publish","description":"Publish job ads"}],
+		"inherited":[{"id":2,"resource":"job","action":"view","description":"","inheritedFrom":"user"}]}`,
+		rec.Body.String())
+}
+
+func TestUpdateRoleHandler_SetParent(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("WITH RECURSIVE ancestors").
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+	mock.ExpectExec("UPDATE roles SET name").
+		WithArgs("employer", "", 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/roles/3", strings.NewReader(`{"name":"employer","parentId":2}`)),
+		map[string]string{"id": "3"})
+	rec := httptest.NewRecorder()
+	handlers.UpdateRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Leaving parentId out keeps the role where it is in the hierarchy.
+func TestUpdateRoleHandler_KeepsParent(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT parent_id FROM roles WHERE id = \\$1 FOR UPDATE").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(2))
+	mock.ExpectExec("UPDATE roles SET name").
+		WithArgs("employer", "Hires people", 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/roles/3",
+		strings.NewReader(`{"name":"employer","description":"Hires people"}`)), map[string]string{"id": "3"})
+	rec := httptest.NewRecorder()
+	handlers.UpdateRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"parentId":2`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateRoleHandler_Cycle(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	// Role 1 is an ancestor of role 3, so 3 cannot become 1's parent.
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("WITH RECURSIVE ancestors").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(2).AddRow(1))
+	mock.ExpectRollback()
+
+	req := mux.SetURLVars(httptest.NewRequest("PUT", "/admin/roles/1", strings.NewReader(`{"name":"user","parentId":3}`)),
+		map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+	handlers.UpdateRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
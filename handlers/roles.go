@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"auth-service/authz"
+	"database/sql"
+	"os"
+	"strings"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadUserRoles returns a user's effective roles: those assigned directly,
+// those of every group the user belongs to, and the ancestors of both.
+// Grants outside their validity window are left out. Held
+// roles come before inherited ones, oldest first, so the first entry is a
+// stable choice for the legacy "role" claim.
+func loadUserRoles(q queryer, userID int) ([]string, error) {
+	return authz.UserRoles(q, userID)
+}
+
+// queryStrings runs a query returning a single text column.
+func queryStrings(q queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// registrationRoles lists the roles users may pick for themselves at
+// registration, from REGISTRATION_ROLES (comma-separated). The first one is
+// the default.
+func registrationRoles() []string {
+	var roles []string
+	for _, role := range strings.Split(os.Getenv("REGISTRATION_ROLES"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		roles = []string{"jobseeker", "employer"}
+	}
+	return roles
+}
+
+// contains reports whether value is present in list.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"auth-service/db"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// revokeAllSessions bumps the user's token_version, which invalidates every
+// access token issued so far, and revokes all of their refresh tokens. It
+// returns sql.ErrNoRows if the user does not exist.
+func revokeAllSessions(q execer, username string) error {
+	res, err := q.Exec("UPDATE users SET token_version = token_version + 1 WHERE username = $1", username)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = q.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE username = $1 AND revoked_at IS NULL", username)
+	return err
+}
+
+// revokeAllSessionsTx runs revokeAllSessions in its own transaction.
+func revokeAllSessionsTx(username string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := revokeAllSessions(tx, username); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LogoutAllHandler signs the caller out of every session on every device.
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	claims := r.Context().Value("userClaims").(*jwt.Claims)
+
+	if err := revokeAllSessionsTx(claims.Subject); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Signed out of all sessions"})
+}
+
+// AdminRevokeSessionsHandler revokes every session of the user with the given
+// id, e.g. after a suspension or a suspected compromise.
+func AdminRevokeSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var username string
+	err = db.DB.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&username)
+	if err == nil {
+		err = revokeAllSessionsTx(username)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("All sessions revoked for user %s", username)
+	json.NewEncoder(w).Encode(JSONResponse{"message": "All sessions revoked"})
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	jwt "auth-service/utils"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// withClaims attaches claims to the request the way AuthMiddleware does.
+func withClaims(req *http.Request, claims *jwt.Claims) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), "userClaims", claims))
+}
+
+func userClaims(username string) *jwt.Claims {
+	claims := &jwt.Claims{Username: username, Role: "jobseeker"}
+	claims.Subject = username
+	return claims
+}
+
+func TestLogoutAllHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET token_version = token_version \\+ 1 WHERE username = \\$1").
+		WithArgs("testuser").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE username = \\$1").
+		WithArgs("testuser").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	req := withClaims(httptest.NewRequest("POST", "/logout/all", nil), userClaims("testuser"))
+	rec := httptest.NewRecorder()
+
+	handlers.LogoutAllHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminRevokeSessionsHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT username FROM users WHERE id = \$1`).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("testuser"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET token_version").
+		WithArgs("testuser").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+		WithArgs("testuser").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/users/42/revoke-sessions", nil), map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+
+	handlers.AdminRevokeSessionsHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdminRevokeSessionsHandler_NotFound(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT username FROM users WHERE id = \$1`).
+		WithArgs(42).
+		WillReturnError(sql.ErrNoRows)
+
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/users/42/revoke-sessions", nil), map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+
+	handlers.AdminRevokeSessionsHandler(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,208 @@
+// handlers/token-handler.go
+package handlers
+
+import (
+	"auth-service/db"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// tokenResponse builds the JSON body returned whenever a token pair is issued.
+// "token" is kept for clients written before refresh tokens existed. The
+// token type is "DPoP" for sender-constrained tokens and "Bearer" otherwise.
+func tokenResponse(accessToken, refreshToken, dpopJKT string) JSONResponse {
+	tokenType := "Bearer"
+	if dpopJKT != "" {
+		tokenType = "DPoP"
+	}
+	return JSONResponse{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"tokenType":    tokenType,
+		"expiresIn":    int(jwt.AccessTokenTTL().Seconds()),
+	}
+}
+
+// refreshTokenParams describes a refresh token to issue. An empty FamilyID
+// starts a new family; ParentID links a rotated token to its predecessor (0
+// for none). Access tokens minted from it are addressed to Audience and bound
+// to DPoPJKT when set.
+type refreshTokenParams struct {
+	Username string
+	Audience string
+	DPoPJKT  string
+	FamilyID string
+	ParentID int
+}
+
+// issueRefreshToken stores the hash of a new refresh token and returns the
+// plaintext token.
+func issueRefreshToken(q execer, p refreshTokenParams) (string, error) {
+	token, err := jwt.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	familyID := p.FamilyID
+	if familyID == "" {
+		if familyID, err = jwt.GenerateOpaqueToken(); err != nil {
+			return "", err
+		}
+	}
+
+	parent := sql.NullInt64{Int64: int64(p.ParentID), Valid: p.ParentID != 0}
+	_, err = q.Exec(`INSERT INTO refresh_tokens (username, audience, dpop_jkt, family_id, token_hash, parent_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		p.Username, p.Audience, p.DPoPJKT, familyID, jwt.HashOpaqueToken(token), parent, time.Now().Add(jwt.RefreshTokenTTL()))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// revokeRefreshFamily revokes every live token in a refresh token family.
+func revokeRefreshFamily(q execer, familyID string) error {
+	_, err := q.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", familyID)
+	return err
+}
+
+// revokeRefreshFamilyByToken revokes the family that the given plaintext
+// refresh token belongs to.
+func revokeRefreshFamilyByToken(q execer, refreshToken string) error {
+	_, err := q.Exec(`UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1) AND revoked_at IS NULL`,
+		jwt.HashOpaqueToken(refreshToken))
+	return err
+}
+
+// RefreshHandler exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token can be used once; presenting a token that
+// has already been rotated revokes its whole family, since either the client
+// or an attacker is holding a stolen copy.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var (
+		id                                    int
+		username, audience, dpopJKT, familyID string
+		expiresAt                             time.Time
+		rotatedAt, revokedAt                  sql.NullTime
+	)
+	err = tx.QueryRow(`SELECT id, username, audience, dpop_jkt, family_id, expires_at, rotated_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, jwt.HashOpaqueToken(req.RefreshToken)).
+		Scan(&id, &username, &audience, &dpopJKT, &familyID, &expiresAt, &rotatedAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		} else {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if rotatedAt.Valid {
+		log.Printf("Refresh token reuse detected for user %s; revoking family", username)
+		if err := revokeRefreshFamily(tx, familyID); err != nil {
+			log.Printf("Error revoking refresh token family: %v", err)
+		} else if err := tx.Commit(); err != nil {
+			log.Printf("Database error: %v", err)
+		}
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	// A DPoP-bound family can only be refreshed by the holder of its key.
+	if dpopJKT != "" {
+		proof, err := jwt.VerifyDPoPProof(r.Header.Get("DPoP"), r.Method, jwt.RequestURL(r), "")
+		if err != nil || proof.JKT != dpopJKT {
+			w.Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+			http.Error(w, "Invalid DPoP proof", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var userID, tokenVersion int
+	if err := tx.QueryRow("SELECT id, token_version FROM users WHERE username = $1", username).Scan(&userID, &tokenVersion); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		} else {
+			log.Printf("Database error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	roles, err := loadUserRoles(tx, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = $1", id); err != nil {
+		log.Printf("Error rotating refresh token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	refreshToken, err := issueRefreshToken(tx, refreshTokenParams{
+		Username: username,
+		Audience: audience,
+		DPoPJKT:  dpopJKT,
+		FamilyID: familyID,
+		ParentID: id,
+	})
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v", err)
+		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+	accessToken, err := jwt.GenerateUserToken(jwt.TokenParams{
+		Username:     username,
+		Roles:        roles,
+		Audience:     audience,
+		TokenVersion: tokenVersion,
+		DPoPJKT:      dpopJKT,
+	})
+	if err != nil {
+		log.Printf("Error generating token: %v", err)
+		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokenResponse(accessToken, refreshToken, dpopJKT))
+}
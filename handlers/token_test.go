@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var refreshColumns = []string{"id", "username", "audience", "dpop_jkt", "family_id", "expires_at", "rotated_at", "revoked_at"}
+
+func refreshRequest(token string) *http.Request {
+	req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{"refreshToken":"`+token+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// Successful rotation.
+func TestRefreshHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, username, audience, dpop_jkt, family_id, expires_at, rotated_at, revoked_at").
+		WithArgs(jwt.HashOpaqueToken("old-token")).
+		WillReturnRows(sqlmock.NewRows(refreshColumns).
+			AddRow(7, "testuser", "", "", "fam-1", time.Now().Add(time.Hour), nil, nil))
+	mock.ExpectQuery(`SELECT id, token_version FROM users WHERE username = \$1`).
+		WithArgs("testuser").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "token_version"}).AddRow(1, 0))
+	mock.ExpectQuery("SELECT r.name FROM roles r").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("jobseeker"))
+	mock.ExpectExec("UPDATE refresh_tokens SET rotated_at").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs("testuser", "", "", "fam-1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.RefreshHandler(rec, refreshRequest("old-token"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.NotEmpty(t, response["token"])
+	assert.NotEmpty(t, response["refreshToken"])
+	assert.NotEqual(t, "old-token", response["refreshToken"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Presenting an already-rotated token revokes the whole family.
+func TestRefreshHandler_ReuseRevokesFamily(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, username, audience, dpop_jkt, family_id, expires_at, rotated_at, revoked_at").
+		WithArgs(jwt.HashOpaqueToken("old-token")).
+		WillReturnRows(sqlmock.NewRows(refreshColumns).
+			AddRow(7, "testuser", "", "", "fam-1", time.Now().Add(time.Hour), time.Now(), nil))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+		WithArgs("fam-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.RefreshHandler(rec, refreshRequest("old-token"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Expired refresh token.
+func TestRefreshHandler_Expired(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, username, audience, dpop_jkt, family_id, expires_at, rotated_at, revoked_at").
+		WillReturnRows(sqlmock.NewRows(refreshColumns).
+			AddRow(7, "testuser", "", "", "fam-1", time.Now().Add(-time.Hour), nil, nil))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	handlers.RefreshHandler(rec, refreshRequest("old-token"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+// Unknown refresh token.
+func TestRefreshHandler_Unknown(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, username, audience, dpop_jkt, family_id, expires_at, rotated_at, revoked_at").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	handlers.RefreshHandler(rec, refreshRequest("nope"))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+// Missing refresh token.
+func TestRefreshHandler_Missing(t *testing.T) {
+	req := httptest.NewRequest("POST", "/token/refresh", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+
+	handlers.RefreshHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
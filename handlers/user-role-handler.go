@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"auth-service/authz"
+	"auth-service/db"
+	"auth-service/models"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// AddUserRoleHandler assigns a role to a user, optionally only between
+// validFrom and expiresAt, e.g. for contractors. Assigning a role the user
+// already holds replaces its validity window.
+func AddUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	var grant models.UserRoles
+	if err := json.NewDecoder(r.Body).Decode(&grant); err != nil || grant.RoleID <= 0 {
+		http.Error(w, "roleId is required", http.StatusBadRequest)
+		return
+	}
+	if !validWindow(grant.ValidFrom, grant.ExpiresAt) {
+		http.Error(w, "expiresAt must be after validFrom", http.StatusBadRequest)
+		return
+	}
+	grant.UserID = id
+
+	_, err := db.DB.Exec(`INSERT INTO user_roles (user_id, role_id, valid_from, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, role_id) DO UPDATE SET valid_from = EXCLUDED.valid_from, expires_at = EXCLUDED.expires_at`,
+		grant.UserID, grant.RoleID, grant.ValidFrom, grant.ExpiresAt)
+	if isForeignKeyViolation(err) {
+		http.Error(w, "User or role not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authz.InvalidateAll()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(grant)
+}
+
+// RemoveUserRoleHandler takes a directly assigned role away from a user.
+func RemoveUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, ok := pathID(r, "id")
+	if !ok {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	roleID, ok := pathID(r, "roleId")
+	if !ok {
+		http.Error(w, "Invalid role id", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.DB.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", id, roleID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "User does not have that role", http.StatusNotFound)
+		return
+	}
+
+	authz.InvalidateAll()
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Role removed from user"})
+}
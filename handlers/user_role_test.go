@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddUserRoleHandler_Window(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("INSERT INTO user_roles").
+		WithArgs(42, 3, nil, expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/users/42/roles",
+		strings.NewReader(`{"roleId":3,"expiresAt":"2030-01-01T00:00:00Z"}`)), map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+	handlers.AddUserRoleHandler(rec, req)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddUserRoleHandler_InvalidWindow(t *testing.T) {
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/admin/users/42/roles",
+		strings.NewReader(`{"roleId":3,"validFrom":"2030-01-02T00:00:00Z","expiresAt":"2030-01-01T00:00:00Z"}`)),
+		map[string]string{"id": "42"})
+	rec := httptest.NewRecorder()
+	handlers.AddUserRoleHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRemoveUserRoleHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectExec(`DELETE FROM user_roles WHERE user_id = \$1 AND role_id = \$2`).
+		WithArgs(42, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/admin/users/42/roles/3", nil),
+		map[string]string{"id": "42", "roleId": "3"})
+	rec := httptest.NewRecorder()
+	handlers.RemoveUserRoleHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
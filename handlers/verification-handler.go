@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"auth-service/audit"
+	"auth-service/db"
+	"auth-service/mail"
+	jwt "auth-service/utils"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validEmail accepts a bare address such as alice@example.com, without a
+// display name.
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Address == email && len(email) <= 254
+}
+
+// emailVerificationTTL is how long a verification link stays usable, read
+// from EMAIL_VERIFICATION_TTL as a Go duration. It defaults to 24 hours.
+func emailVerificationTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 24 * time.Hour
+}
+
+// verificationResendInterval is the minimum time between verification mails
+// to one account, read from EMAIL_VERIFICATION_RESEND_INTERVAL as a Go
+// duration. It defaults to 1 minute.
+func verificationResendInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_RESEND_INTERVAL")); err == nil && d >= 0 {
+		return d
+	}
+	return time.Minute
+}
+
+// verificationThrottled writes a 429 and returns true when the last
+// verification mail to an account went out less than
+// verificationResendInterval ago.
+func verificationThrottled(w http.ResponseWriter, lastSent sql.NullTime) bool {
+	wait := verificationResendInterval() - time.Since(lastSent.Time)
+	if !lastSent.Valid || wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Verification email was sent recently; try again later", http.StatusTooManyRequests)
+	return true
+}
+
+// createVerificationToken stores a new verification token for email and
+// returns it. Callers mail it once their transaction has committed.
+func createVerificationToken(q execer, userID int, email string) (string, error) {
+	token, err := jwt.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.Exec("INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at) VALUES ($1, $2, $3, $4)",
+		jwt.HashOpaqueToken(token), userID, email, time.Now().Add(emailVerificationTTL()))
+	return token, err
+}
+
+// mailVerification sends the verification link. EMAIL_VERIFICATION_URL is
+// the front-end page that completes verification; without it the bare token
+// is sent.
+func mailVerification(username, email, token string) error {
+	link := token
+	if base := os.Getenv("EMAIL_VERIFICATION_URL"); base != "" {
+		if u, err := url.Parse(base); err == nil {
+			q := u.Query()
+			q.Set("token", token)
+			u.RawQuery = q.Encode()
+			link = u.String()
+		}
+	}
+	return mail.Send(mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome, %s.\n\nUse this to verify your email address within %s:\n\n%s\n",
+			username, emailVerificationTTL(), link),
+	})
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmailHandler marks the user's email address as verified using a
+// token from the verification mail. The token only works while the address
+// it was sent to is still the account's email or pending email; verifying a
+// pending email makes it the account's email.
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req verifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Token is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := verifyEmail(jwt.HashOpaqueToken(req.Token)); err != nil {
+		if err == errVerificationTokenInvalid {
+			http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
+			return
+		}
+		if err == errEmailTaken {
+			http.Error(w, "Email address is already in use", http.StatusConflict)
+			return
+		}
+		log.Printf("Error verifying email: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(JSONResponse{"message": "Email address verified"})
+}
+
+var (
+	errVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	errEmailTaken               = errors.New("email address is already in use")
+)
+
+func verifyEmail(tokenHash string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var username, email, oldEmail string
+	err = tx.QueryRow(`SELECT u.id, u.username, t.email, COALESCE(u.email, '') FROM email_verification_tokens t
+		JOIN users u ON u.id = t.user_id
+			AND (LOWER(u.email) = LOWER(t.email) OR LOWER(u.pending_email) = LOWER(t.email))
+		WHERE t.token_hash = $1 AND t.expires_at > NOW()`, tokenHash).Scan(&userID, &username, &email, &oldEmail)
+	if err == sql.ErrNoRows {
+		return errVerificationTokenInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	// Verifying a pending address makes it the account's email.
+	_, err = tx.Exec(`UPDATE users SET email = $2, email_verified = TRUE,
+		pending_email = CASE WHEN LOWER(pending_email) = LOWER($2) THEN NULL ELSE pending_email END
+		WHERE id = $1`, userID, email)
+	if isUniqueViolation(err) {
+		return errEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM email_verification_tokens WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if err := audit.Record(tx, audit.Event{Type: audit.EmailVerified, Actor: username, Subject: username}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// Tell the previous address, so an unexpected change can be noticed by
+	// the account's owner.
+	if oldEmail != "" && !strings.EqualFold(oldEmail, email) {
+		if err := mailEmailChanged(username, oldEmail, email); err != nil {
+			log.Printf("Error sending email change notice to %s: %v", username, err)
+		}
+	}
+	return nil
+}
+
+// mailEmailChanged notifies the previous address that the account's email
+// has moved to newEmail.
+func mailEmailChanged(username, oldEmail, newEmail string) error {
+	return mail.Send(mail.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hello, %s.\n\nThe email address on your account was changed to %s.\n"+
+			"If you did not make this change, reset your password and contact support.\n", username, newEmail),
+	})
+}
+
+type resendVerificationRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// ResendVerificationHandler mails a fresh verification link to an account
+// that has not verified its email yet. Mails to one account are at least
+// verificationResendInterval apart; earlier requests are ignored. The
+// response is always the same, so it cannot be used to discover accounts.
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req resendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if (req.Email == "") == (req.Username == "") {
+		http.Error(w, "Exactly one of email and username is required", http.StatusBadRequest)
+		return
+	}
+
+	var userID int
+	var username, email string
+	var lastSent sql.NullTime
+	err := db.DB.QueryRow(`SELECT u.id, u.username, u.email,
+		(SELECT MAX(created_at) FROM email_verification_tokens WHERE user_id = u.id)
+		FROM users u
+		WHERE (LOWER(u.email) = LOWER($1) OR u.username = $2) AND u.email IS NOT NULL AND NOT u.email_verified
+			AND u.deleted_at IS NULL`,
+		req.Email, req.Username).Scan(&userID, &username, &email, &lastSent)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Database error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err == nil && lastSent.Valid && time.Since(lastSent.Time) < verificationResendInterval() {
+		log.Printf("Verification for %s requested again too soon; not sending", username)
+	} else if err == nil {
+		if err := resendVerification(userID, username, email); err != nil {
+			log.Printf("Error resending verification for %s: %v", username, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(JSONResponse{"message": "If the account needs verification, a new link has been sent"})
+}
+
+// resendVerification replaces the user's outstanding tokens with a new one
+// and mails it.
+func resendVerification(userID int, username, email string) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM email_verification_tokens WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	token, err := createVerificationToken(tx, userID, email)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return mailVerification(username, email, token)
+}
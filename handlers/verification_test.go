@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"auth-service/handlers"
+	jwt "auth-service/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEmailHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id, u.username, t.email, COALESCE\\(u.email, ''\\) FROM email_verification_tokens t").
+		WithArgs(jwt.HashOpaqueToken("verify-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "old_email"}).
+			AddRow(1, "alice", "alice@example.com", "alice@example.com"))
+	mock.ExpectExec("UPDATE users SET email = \\$2, email_verified = TRUE").
+		WithArgs(1, "alice@example.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM email_verification_tokens WHERE user_id = \\$1").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("email.verified", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.VerifyEmailHandler(rec, jsonRequest("POST", "/email/verify", `{"token":"verify-token"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Verifying a pending address tells the previous one about the change.
+func TestVerifyEmailHandler_PendingEmail(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id, u.username, t.email").
+		WithArgs(jwt.HashOpaqueToken("verify-token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "old_email"}).
+			AddRow(1, "alice", "alice@new.example.com", "alice@example.com"))
+	mock.ExpectExec("UPDATE users SET email = \\$2, email_verified = TRUE").
+		WithArgs(1, "alice@new.example.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM email_verification_tokens WHERE user_id = \\$1").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs("email.verified", "alice", "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.VerifyEmailHandler(rec, jsonRequest("POST", "/email/verify", `{"token":"verify-token"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, box.sent, 1)
+	assert.Equal(t, "alice@example.com", box.sent[0].To)
+	assert.Contains(t, box.sent[0].Body, "alice@new.example.com")
+}
+
+func TestVerifyEmailHandler_InvalidToken(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id, u.username, t.email").
+		WithArgs(jwt.HashOpaqueToken("stale")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "old_email"}))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	handlers.VerifyEmailHandler(rec, jsonRequest("POST", "/email/verify", `{"token":"stale"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResendVerificationHandler(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs("", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "max"}).
+			AddRow(1, "alice", "alice@example.com", time.Now().Add(-time.Hour)))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM email_verification_tokens WHERE user_id = \\$1").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO email_verification_tokens").
+		WithArgs(sqlmock.AnyArg(), 1, "alice@example.com", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	handlers.ResendVerificationHandler(rec, jsonRequest("POST", "/email/verify/resend", `{"username":"alice"}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, box.sent, 1)
+	assert.Equal(t, "Verify your email address", box.sent[0].Subject)
+}
+
+// A repeated request within the resend interval gets the same response but
+// sends nothing.
+func TestResendVerificationHandler_Throttled(t *testing.T) {
+	mock, cleanup := setupMockDB()
+	defer cleanup()
+	box := useOutbox(t)
+
+	mock.ExpectQuery("SELECT u.id, u.username, u.email").
+		WithArgs("alice@example.com", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "max"}).
+			AddRow(1, "alice", "alice@example.com", time.Now().Add(-10*time.Second)))
+
+	rec := httptest.NewRecorder()
+	handlers.ResendVerificationHandler(rec, jsonRequest("POST", "/email/verify/resend", `{"email":"alice@example.com"}`))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, box.sent)
+}
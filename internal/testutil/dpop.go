@@ -0,0 +1,37 @@
+// Package testutil holds helpers shared by tests in several packages.
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// DPoPProof signs a DPoP proof for method and url with key, issued at iat.
+// The proof is bound to accessToken through ath unless accessToken is empty.
+func DPoPProof(t *testing.T, key *ecdsa.PrivateKey, method, url, accessToken string, iat time.Time) string {
+	t.Helper()
+	jwk, err := jwt.PublicKeyToJWK(&key.PublicKey)
+	require.NoError(t, err)
+	jti, err := jwt.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	claims := gojwt.MapClaims{"htm": method, "htu": url, "jti": jti, "iat": iat.Unix()}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	token := gojwt.NewWithClaims(gojwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jwk
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
@@ -0,0 +1,138 @@
+// Package mail sends the service's transactional email, such as password
+// reset links. Delivery goes through a Sender so that development setups can
+// log or write messages to disk instead of talking to an SMTP server.
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// LogSender writes messages to the standard logger. It is the default, so
+// that a local setup works without any mail configuration.
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender writes each message to its own file in Dir, which is handy for
+// inspecting mail in development and end-to-end tests.
+type FileSender struct {
+	Dir string
+}
+
+func (s FileSender) Send(msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(s.Dir, name), format("", msg), 0o600)
+}
+
+// SMTPSender delivers messages through an SMTP server.
+type SMTPSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func (s SMTPSender) Send(msg Message) error {
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{msg.To}, format(s.From, msg))
+}
+
+// format renders msg as an RFC 5322 message.
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\nSubject: %s\r\n", msg.To, msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// SenderFromEnv builds a sender from MAIL_SENDER: "log" (the default),
+// "file", which writes to MAIL_FILE_DIR, or "smtp", which uses SMTP_ADDR,
+// MAIL_FROM and, when SMTP_USERNAME is set, PLAIN authentication with
+// SMTP_PASSWORD. When APP_ENV is "prod" MAIL_SENDER must be set, since the
+// log sender writes reset and verification tokens to the log.
+func SenderFromEnv() (Sender, error) {
+	switch kind := os.Getenv("MAIL_SENDER"); kind {
+	case "":
+		if os.Getenv("APP_ENV") == "prod" {
+			return nil, fmt.Errorf("MAIL_SENDER must be set in production")
+		}
+		return LogSender{}, nil
+	case "log":
+		return LogSender{}, nil
+	case "file":
+		dir := os.Getenv("MAIL_FILE_DIR")
+		if dir == "" {
+			return nil, fmt.Errorf("MAIL_FILE_DIR must be set for the file mail sender")
+		}
+		return FileSender{Dir: dir}, nil
+	case "smtp":
+		addr, from := os.Getenv("SMTP_ADDR"), os.Getenv("MAIL_FROM")
+		if addr == "" || from == "" {
+			return nil, fmt.Errorf("SMTP_ADDR and MAIL_FROM must be set for the smtp mail sender")
+		}
+		sender := SMTPSender{Addr: addr, From: from}
+		if user := os.Getenv("SMTP_USERNAME"); user != "" {
+			host, _, _ := strings.Cut(addr, ":")
+			sender.Auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return sender, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_SENDER %q", kind)
+	}
+}
+
+var (
+	senderMu sync.RWMutex
+	sender   Sender = LogSender{}
+)
+
+// SetSender installs the sender used by Send.
+func SetSender(s Sender) {
+	senderMu.Lock()
+	defer senderMu.Unlock()
+	sender = s
+}
+
+// Send delivers msg with the installed sender.
+func Send(msg Message) error {
+	senderMu.RLock()
+	s := sender
+	senderMu.RUnlock()
+	return s.Send(msg)
+}
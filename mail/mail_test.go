@@ -0,0 +1,59 @@
+package mail_test
+
+import (
+	"auth-service/mail"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := mail.FileSender{Dir: dir}
+	require.NoError(t, sender.Send(mail.Message{To: "alice@example.com", Subject: "Hello", Body: "line one\nline two"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "To: alice@example.com\r\nSubject: Hello\r\n")
+	assert.Contains(t, string(raw), "line one\r\nline two")
+}
+
+func TestSenderFromEnv(t *testing.T) {
+	t.Setenv("MAIL_SENDER", "")
+	sender, err := mail.SenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, mail.LogSender{}, sender)
+
+	t.Setenv("APP_ENV", "prod")
+	_, err = mail.SenderFromEnv()
+	assert.Error(t, err)
+	t.Setenv("MAIL_SENDER", "log")
+	sender, err = mail.SenderFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, mail.LogSender{}, sender)
+
+	t.Setenv("MAIL_SENDER", "file")
+	_, err = mail.SenderFromEnv()
+	assert.Error(t, err)
+	t.Setenv("MAIL_FILE_DIR", "/tmp/mail")
+	sender, err = mail.SenderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, mail.FileSender{Dir: "/tmp/mail"}, sender)
+
+	t.Setenv("MAIL_SENDER", "smtp")
+	t.Setenv("SMTP_ADDR", "localhost:25")
+	t.Setenv("MAIL_FROM", "no-reply@example.com")
+	sender, err = mail.SenderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:25", sender.(mail.SMTPSender).Addr)
+
+	t.Setenv("MAIL_SENDER", "pigeon")
+	_, err = mail.SenderFromEnv()
+	assert.Error(t, err)
+}
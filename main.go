@@ -1,14 +1,21 @@
 package main
 
 import (
+	"auth-service/account"
+	"auth-service/authz"
 	"auth-service/db"
+	"auth-service/mail"
+	"auth-service/rebac"
 	"auth-service/routes"
 	"auth-service/secretmanager" // Ensure this is available in production.
+	jwt "auth-service/utils"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata" // Profile time zones are validated even where the image has no zoneinfo.
 
 	"github.com/gorilla/handlers"
 	"github.com/joho/godotenv"
@@ -51,6 +58,31 @@ func loadProdSecrets() {
 	os.Setenv("DB_INSTANCE_IDENTIFIER", pgSecrets["dbInstanceIdentifier"].(string))
 }
 
+// setupKeyRotation loads the shared keyring from Postgres and keeps it in sync,
+// rotating the active signing key every interval.
+func setupKeyRotation(interval time.Duration) {
+	alg := os.Getenv("JWT_SIGNING_ALG")
+	if alg == "" {
+		alg = "HS256"
+	}
+	store, err := jwt.NewSQLKeyStore(db.DB, alg, interval, os.Getenv("JWT_KEY_ENCRYPTION_KEY"))
+	if err != nil {
+		log.Fatalf("Error configuring signing key store: %v", err)
+	}
+	// Tokens signed with the environment key before rotation was enabled
+	// stay valid until they would have expired anyway.
+	if key, err := jwt.EnvSigningKey(); err == nil {
+		store.KeepVerifying(key, time.Now())
+	}
+	ring := &jwt.Keyring{}
+	if err := store.Sync(ring); err != nil {
+		log.Fatalf("Error loading signing keys: %v", err)
+	}
+	jwt.SetKeyring(ring)
+	jwt.StartKeyRotation(store, ring, time.Minute)
+	log.Printf("Signing keys are rotated every %s", interval)
+}
+
 func main() {
 	// Always attempt to load the .env file.
 	if err := godotenv.Load(); err != nil {
@@ -69,8 +101,54 @@ func main() {
 		loadProdSecrets()
 	}
 
-	// Connect to the database.
+	// Connect to the database and bring the schema up to date.
 	db.Connect()
+	if err := db.Migrate(); err != nil {
+		log.Fatalf("Error running migrations: %v", err)
+	}
+
+	// Share revoked token IDs across instances and purge them once expired.
+	jwt.SetDenylist(jwt.NewSQLDenylist(db.DB))
+	jwt.StartDenylistSweeper(10 * time.Minute)
+
+	// Reject tokens minted before a user's sessions were revoked.
+	jwt.SetTokenVersionLookup(jwt.SQLTokenVersionLookup(db.DB))
+
+	// Reference tokens (ACCESS_TOKEN_FORMAT=reference) resolve through
+	// Postgres with a short-lived in-memory cache in front.
+	jwt.SetReferenceTokenStore(jwt.NewCachedReferenceStore(jwt.NewSQLReferenceStore(db.DB), 30*time.Second))
+	jwt.StartReferenceTokenSweeper(10 * time.Minute)
+	jwt.StartDPoPReplaySweeper(time.Minute)
+
+	// Cache effective permissions; the watcher drops the cache whenever
+	// roles, groups or grants change on any instance.
+	resolver := authz.NewResolver(authz.NewSQLSource(db.DB), time.Minute)
+	authz.SetResolver(resolver)
+	authz.StartInvalidationWatcher(resolver, 5*time.Second)
+	authz.StartGrantSweeper(db.DB, time.Minute)
+	account.StartPurger(db.DB, time.Hour)
+
+	// Relationship checks follow the default job board schema unless
+	// REBAC_SCHEMA_FILE points at another one.
+	schema, err := rebac.SchemaFromEnv()
+	if err != nil {
+		log.Fatalf("Error loading relationship schema: %v", err)
+	}
+	rebac.SetChecker(rebac.NewChecker(schema, rebac.NewSQLStore(db.DB)))
+
+	// With JWT_KEY_ROTATION_INTERVAL set, signing keys live in Postgres and
+	// are rotated on that schedule by whichever instance gets there first.
+	if interval, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION_INTERVAL")); err == nil && interval > 0 {
+		setupKeyRotation(interval)
+	}
+
+	// Transactional mail is logged unless MAIL_SENDER selects another sender;
+	// production must choose one explicitly.
+	sender, err := mail.SenderFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring mail: %v", err)
+	}
+	mail.SetSender(sender)
 
 	// Setup routes.
 	router := routes.SetupRoutes()
@@ -79,7 +157,7 @@ func main() {
 	corsOpts := []handlers.CORSOption{
 		handlers.AllowedOrigins([]string{"*"}), // allow all origins
 		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Requested-With"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Requested-With", "DPoP"}),
 		handlers.AllowCredentials(), // if you need cookies/auth
 	}
 
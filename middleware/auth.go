@@ -3,6 +3,7 @@ package middleware
 
 import (
 	"auth-service/utils"
+	"context"
 	"net/http"
 	"strings"
 )
@@ -15,28 +16,78 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, isExpired, err := utils.ValidateToken(token)
+		scheme, token := jwt.AuthorizationToken(authHeader)
+		claims, isExpired, err := jwt.ValidateToken(token)
 		if err != nil || isExpired {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
+		// DPoP-bound tokens must be presented with the DPoP scheme and a
+		// fresh proof signed by the bound key (RFC 9449 section 7).
+		if claims.Cnf != nil && claims.Cnf.JKT != "" {
+			proof, err := jwt.VerifyDPoPProof(r.Header.Get("DPoP"), r.Method, jwt.RequestURL(r), token)
+			if !strings.EqualFold(scheme, "DPoP") || err != nil || proof.JKT != claims.Cnf.JKT {
+				w.Header().Set("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+				http.Error(w, "Invalid DPoP proof", http.StatusUnauthorized)
+				return
+			}
+		} else if strings.EqualFold(scheme, "DPoP") {
+			http.Error(w, "Token is not DPoP-bound", http.StatusUnauthorized)
+			return
+		}
+
+		// Delegated and down-scoped tokens act for the user in a limited way
+		// and are meant for downstream services, not the user's own account.
+		if claims.Restricted() {
+			http.Error(w, "A full user token is required", http.StatusForbidden)
+			return
+		}
+
 		// Add claims to context for downstream handlers
 		ctx := context.WithValue(r.Context(), "userClaims", claims)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// RoleMiddleware allows the request through if the caller holds one of the
+// allowed roles.
+//
+// Deprecated: protect routes with RequirePermission instead, so access is
+// managed through role permissions rather than hard-coded role names.
 func RoleMiddleware(allowedRoles []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims, ok := r.Context().Value("userClaims").(*utils.Claims)
-			if !ok || !contains(allowedRoles, claims.Role) {
+			claims, ok := r.Context().Value("userClaims").(*jwt.Claims)
+			if !ok || !hasAnyRole(claims, allowedRoles) {
 				http.Error(w, "Forbidden", http.StatusForbidden)
 				return
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
-}
\ No newline at end of file
+}
+
+// hasAnyRole reports whether the token carries one of the allowed roles.
+// Tokens issued before multiple roles existed only carry Role.
+func hasAnyRole(claims *jwt.Claims, allowedRoles []string) bool {
+	if contains(allowedRoles, claims.Role) {
+		return true
+	}
+	for _, role := range claims.Roles {
+		if contains(allowedRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether value is present in list.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
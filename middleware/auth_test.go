@@ -0,0 +1,120 @@
+package middleware_test
+
+import (
+	"auth-service/internal/testutil"
+	"auth-service/middleware"
+	jwt "auth-service/utils"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setTokenEnv() {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	os.Setenv("JWT_ISSUER", "test-issuer")
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestAuthMiddleware(t *testing.T) {
+	setTokenEnv()
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/authenticate", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	middleware.AuthMiddleware(okHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest("GET", "/authenticate", nil)
+	rec = httptest.NewRecorder()
+	middleware.AuthMiddleware(okHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddleware_DPoPBoundToken(t *testing.T) {
+	setTokenEnv()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk, _ := jwt.PublicKeyToJWK(&key.PublicKey)
+	jkt, _ := jwt.Thumbprint(jwk)
+
+	token, err := jwt.GenerateUserToken(jwt.TokenParams{Username: "testuser", Role: "employer", DPoPJKT: jkt})
+	require.NoError(t, err)
+
+	// With a valid proof.
+	req := httptest.NewRequest("GET", "http://example.com/authenticate", nil)
+	req.Header.Set("Authorization", "DPoP "+token)
+	req.Header.Set("DPoP", testutil.DPoPProof(t, key, "GET", "http://example.com/authenticate", token, time.Now()))
+	rec := httptest.NewRecorder()
+	middleware.AuthMiddleware(okHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Replayed as a plain bearer token.
+	req = httptest.NewRequest("GET", "http://example.com/authenticate", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	middleware.AuthMiddleware(okHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// With a proof from a different key.
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	req = httptest.NewRequest("GET", "http://example.com/authenticate", nil)
+	req.Header.Set("Authorization", "DPoP "+token)
+	req.Header.Set("DPoP", testutil.DPoPProof(t, other, "GET", "http://example.com/authenticate", token, time.Now()))
+	rec = httptest.NewRecorder()
+	middleware.AuthMiddleware(okHandler).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddleware_DelegatedToken(t *testing.T) {
+	setTokenEnv()
+	for name, claims := range map[string]*jwt.Claims{
+		"scoped":    {Username: "testuser", Role: "employer", Scope: "applications:read"},
+		"delegated": {Username: "testuser", Role: "employer", Act: &jwt.Actor{Subject: "gateway"}},
+	} {
+		claims.Subject = "testuser"
+		token, err := jwt.IssueToken(claims, time.Minute)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		middleware.AuthMiddleware(okHandler).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code, name)
+	}
+}
+
+func TestRoleMiddleware_AnyRole(t *testing.T) {
+	setTokenEnv()
+	token, err := jwt.GenerateUserToken(jwt.TokenParams{Username: "testuser", Roles: []string{"jobseeker", "admin"}})
+	require.NoError(t, err)
+
+	handler := middleware.AuthMiddleware(middleware.RoleMiddleware([]string{"admin"})(okHandler))
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	token, err = jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+	req = httptest.NewRequest("GET", "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
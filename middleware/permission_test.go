@@ -0,0 +1,80 @@
+package middleware_test
+
+import (
+	"auth-service/authz"
+	"auth-service/middleware"
+	jwt "auth-service/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticSource grants every user the same permissions.
+type staticSource []authz.Grant
+
+func (s staticSource) UserID(subject string) (int, error)       { return 1, nil }
+func (s staticSource) Grants(userID int) ([]authz.Grant, error) { return s, nil }
+func (s staticSource) Revision() (int64, error)                 { return 0, nil }
+
+func TestRequirePermission(t *testing.T) {
+	setTokenEnv()
+	authz.SetResolver(authz.NewResolver(staticSource{
+		{Permission: authz.Permission{Resource: "job", Action: "publish"}, Role: "employer"},
+	}, 0))
+	defer authz.SetResolver(nil)
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	serve := func(resource, action string) *httptest.ResponseRecorder {
+		handler := middleware.AuthMiddleware(middleware.RequirePermission(resource, action)(okHandler))
+		req := httptest.NewRequest("POST", "/jobs", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, serve("job", "publish").Code)
+
+	rec := serve("job", "delete")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "forbidden", body["error"])
+	assert.Equal(t, map[string]interface{}{"resource": "job", "action": "delete"}, body["missingPermission"])
+}
+
+func TestRequirePermission_NoClaims(t *testing.T) {
+	rec := httptest.NewRecorder()
+	middleware.RequirePermission("job", "publish")(okHandler).ServeHTTP(rec, httptest.NewRequest("POST", "/jobs", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireInstancePermission(t *testing.T) {
+	setTokenEnv()
+	authz.SetResolver(authz.NewResolver(staticSource{
+		{Permission: authz.Permission{Resource: "job", Instance: "1234", Action: "edit"}, Role: "employer"},
+	}, 0))
+	defer authz.SetResolver(nil)
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Handle("/jobs/{id}", middleware.AuthMiddleware(
+		middleware.RequireInstancePermission("job", "edit", "id")(okHandler)))
+
+	for id, status := range map[string]int{"1234": http.StatusOK, "5678": http.StatusForbidden} {
+		req := httptest.NewRequest("PUT", "/jobs/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code, id)
+	}
+}
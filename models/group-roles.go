@@ -1,6 +1,12 @@
 package models
 
+import "time"
+
+// GroupRoles assigns a role to every member of a group, optionally only
+// between ValidFrom and ExpiresAt.
 type GroupRoles struct {
-	GroupID int `json:"groupId"`
-	RoleID  int `json:"roleId"`
+	GroupID   int        `json:"groupId"`
+	RoleID    int        `json:"roleId"`
+	ValidFrom *time.Time `json:"validFrom,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
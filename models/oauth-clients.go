@@ -0,0 +1,7 @@
+package models
+
+type OAuthClients struct {
+	ClientID   string `json:"clientId"`
+	SecretHash string `json:"-"`
+	Name       string `json:"name"`
+}
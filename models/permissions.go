@@ -1,8 +1,11 @@
 package models
 
+// Permissions is a row of the permissions table. Instance, when set, limits
+// the permission to one instance of Resource; "*" in any field is a wildcard.
 type Permissions struct {
 	ID          int    `json:"id"`
 	Resource    string `json:"resource"`
+	Instance    string `json:"instance,omitempty"`
 	Action      string `json:"action"`
 	Description string `json:"description"`
 }
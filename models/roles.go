@@ -1,7 +1,10 @@
 package models
 
+// Roles is a row of the roles table. A role inherits the permissions of its
+// parent, if it has one.
 type Roles struct {
 	ID           int    `json:"id"`
 	Name         string `json:"name"`
 	Descriptions string `json:"description"`
+	ParentID     *int   `json:"parentId"`
 }
@@ -1,6 +1,12 @@
 package models
 
+import "time"
+
+// UserRoles assigns a role to a user, optionally only between ValidFrom and
+// ExpiresAt.
 type UserRoles struct {
-	UserID int `json:"userId"`
-	RoleID int `json:"roleId"`
+	UserID    int        `json:"userId"`
+	RoleID    int        `json:"roleId"`
+	ValidFrom *time.Time `json:"validFrom,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 }
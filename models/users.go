@@ -1,9 +1,20 @@
 // models/user.g
 package models
 
+// Users is a row of the users table. Role and Roles are only used on
+// registration; a user's roles live in user_roles. EmailVerified is set once
+// the user follows the link mailed to Email. A changed address waits in
+// PendingEmail until it is verified.
 type Users struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	ID            int      `json:"id"`
+	Username      string   `json:"username"`
+	Password      string   `json:"password"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"emailVerified"`
+	PendingEmail  string   `json:"pendingEmail,omitempty"`
+	DisplayName   string   `json:"displayName,omitempty"`
+	Locale        string   `json:"locale,omitempty"`
+	Timezone      string   `json:"timezone,omitempty"`
+	Role          string   `json:"role"`
+	Roles         []string `json:"roles,omitempty"`
 }
@@ -0,0 +1,139 @@
+package rebac
+
+import (
+	"auth-service/db"
+	"errors"
+	"sync"
+)
+
+// ErrMaxDepth is returned when a check needs more than MaxDepth steps, which
+// usually means the tuples form a very deep or cyclic userset chain.
+var ErrMaxDepth = errors.New("relationship check exceeded the maximum depth")
+
+// MaxDepth bounds how many relations a single check may follow.
+const MaxDepth = 25
+
+// Checker answers relationship checks against a Store using a Schema.
+type Checker struct {
+	Schema Schema
+	Store  Store
+}
+
+// NewChecker returns a checker for schema over store.
+func NewChecker(schema Schema, store Store) *Checker {
+	return &Checker{Schema: schema, Store: store}
+}
+
+// Check reports whether subject has relation to object, following the
+// schema's userset rewrites. A userset subject such as company:7#recruiter is
+// allowed when that userset is itself related.
+func (c *Checker) Check(object Object, relation string, subject Subject) (bool, error) {
+	return c.check(object, relation, subject, 0, make(map[visit]bool))
+}
+
+type visit struct {
+	object   Object
+	relation string
+}
+
+func (c *Checker) check(object Object, relation string, subject Subject, depth int, seen map[visit]bool) (bool, error) {
+	if depth > MaxDepth {
+		return false, ErrMaxDepth
+	}
+	// The subject is trivially in its own userset.
+	if subject.Relation == relation && subject.Object == object {
+		return true, nil
+	}
+	// Revisiting an (object, relation) on the current path is a cycle and
+	// cannot add anything new.
+	v := visit{object, relation}
+	if seen[v] {
+		return false, nil
+	}
+	seen[v] = true
+	defer delete(seen, v)
+
+	def, ok := c.Schema[object.Type][relation]
+	if !ok {
+		return false, nil
+	}
+	for _, u := range def.usersets() {
+		var allowed bool
+		var err error
+		switch {
+		case u.This:
+			allowed, err = c.checkThis(object, relation, subject, depth, seen)
+		case u.ComputedUserset != "":
+			allowed, err = c.check(object, u.ComputedUserset, subject, depth+1, seen)
+		case u.TupleToUserset != nil:
+			allowed, err = c.checkTupleToUserset(object, *u.TupleToUserset, subject, depth, seen)
+		}
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+	return false, nil
+}
+
+// checkThis looks at the tuples written for relation itself: a direct match,
+// or a userset subject that in turn contains subject.
+func (c *Checker) checkThis(object Object, relation string, subject Subject, depth int, seen map[visit]bool) (bool, error) {
+	subjects, err := c.Store.Subjects(object, relation)
+	if err != nil {
+		return false, err
+	}
+	var usersets []Subject
+	for _, s := range subjects {
+		if s == subject {
+			return true, nil
+		}
+		if s.Relation != "" {
+			usersets = append(usersets, s)
+		}
+	}
+	for _, s := range usersets {
+		allowed, err := c.check(s.Object, s.Relation, subject, depth+1, seen)
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+	return false, nil
+}
+
+func (c *Checker) checkTupleToUserset(object Object, rewrite TupleToUserset, subject Subject, depth int, seen map[visit]bool) (bool, error) {
+	related, err := c.Store.Subjects(object, rewrite.Tupleset)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range related {
+		allowed, err := c.check(s.Object, rewrite.ComputedUserset, subject, depth+1, seen)
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+	return false, nil
+}
+
+var (
+	checkerMu sync.RWMutex
+	checker   *Checker
+)
+
+// SetChecker installs the checker returned by Default.
+func SetChecker(c *Checker) {
+	checkerMu.Lock()
+	defer checkerMu.Unlock()
+	checker = c
+}
+
+// Default returns the installed checker, or one using DefaultSchema over
+// db.DB when none is installed.
+func Default() *Checker {
+	checkerMu.RLock()
+	c := checker
+	checkerMu.RUnlock()
+	if c != nil {
+		return c
+	}
+	return NewChecker(DefaultSchema, NewSQLStore(db.DB))
+}
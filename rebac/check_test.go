@@ -0,0 +1,105 @@
+package rebac_test
+
+import (
+	"auth-service/rebac"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustTuple(t *testing.T, object, relation, subject string) rebac.Tuple {
+	o, err := rebac.ParseObject(object)
+	require.NoError(t, err)
+	s, err := rebac.ParseSubject(subject)
+	require.NoError(t, err)
+	return rebac.Tuple{Object: o, Relation: relation, Subject: s}
+}
+
+// jobBoard has company 7 owned by carol with alice as a recruiter, and job 42
+// belonging to company 7 with dave as a viewer.
+func jobBoard(t *testing.T) *rebac.Checker {
+	store := rebac.NewMemoryStore()
+	for _, tuple := range []rebac.Tuple{
+		mustTuple(t, "company:7", "owner", "user:carol"),
+		mustTuple(t, "company:7", "recruiter", "user:alice"),
+		mustTuple(t, "job:42", "company", "company:7"),
+		mustTuple(t, "job:42", "viewer", "user:dave"),
+	} {
+		require.NoError(t, store.Write(tuple))
+	}
+	return rebac.NewChecker(rebac.DefaultSchema, store)
+}
+
+func TestCheck_JobBoard(t *testing.T) {
+	checker := jobBoard(t)
+	job := rebac.Object{Type: "job", ID: "42"}
+	user := func(id string) rebac.Subject { return rebac.Subject{Object: rebac.Object{Type: "user", ID: id}} }
+
+	for _, tc := range []struct {
+		relation, user string
+		allowed        bool
+	}{
+		{"editor", "alice", true}, // recruiter of the owning company
+		{"owner", "alice", false}, // recruiting is not owning
+		{"owner", "carol", true},  // company owner owns its jobs
+		{"editor", "carol", true}, // owners recruit, and owners edit
+		{"viewer", "alice", true}, // editors view
+		{"viewer", "dave", true},  // direct tuple
+		{"editor", "dave", false}, // viewing does not imply editing
+		{"editor", "mallory", false},
+	} {
+		allowed, err := checker.Check(job, tc.relation, user(tc.user))
+		require.NoError(t, err)
+		assert.Equal(t, tc.allowed, allowed, "%s %s", tc.user, tc.relation)
+	}
+
+	allowed, err := checker.Check(rebac.Object{Type: "job", ID: "43"}, "editor", user("alice"))
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestCheck_UsersetSubject(t *testing.T) {
+	checker := jobBoard(t)
+	require.NoError(t, checker.Store.Write(mustTuple(t, "job:43", "editor", "company:7#recruiter")))
+
+	allowed, err := checker.Check(rebac.Object{Type: "job", ID: "43"}, "editor",
+		rebac.Subject{Object: rebac.Object{Type: "user", ID: "alice"}})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// The userset itself is an editor too.
+	allowed, err = checker.Check(rebac.Object{Type: "job", ID: "43"}, "editor",
+		rebac.Subject{Object: rebac.Object{Type: "company", ID: "7"}, Relation: "recruiter"})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestCheck_Cycle(t *testing.T) {
+	store := rebac.NewMemoryStore()
+	require.NoError(t, store.Write(mustTuple(t, "company:1", "recruiter", "company:2#recruiter")))
+	require.NoError(t, store.Write(mustTuple(t, "company:2", "recruiter", "company:1#recruiter")))
+	checker := rebac.NewChecker(rebac.DefaultSchema, store)
+
+	allowed, err := checker.Check(rebac.Object{Type: "company", ID: "1"}, "recruiter",
+		rebac.Subject{Object: rebac.Object{Type: "user", ID: "alice"}})
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestCheck_MaxDepth(t *testing.T) {
+	store := rebac.NewMemoryStore()
+	for i := 0; i < rebac.MaxDepth+2; i++ {
+		tuple := rebac.Tuple{
+			Object:   rebac.Object{Type: "company", ID: string(rune('a' + i))},
+			Relation: "recruiter",
+			Subject:  rebac.Subject{Object: rebac.Object{Type: "company", ID: string(rune('a' + i + 1))}, Relation: "recruiter"},
+		}
+		require.NoError(t, store.Write(tuple))
+	}
+	checker := rebac.NewChecker(rebac.DefaultSchema, store)
+
+	_, err := checker.Check(rebac.Object{Type: "company", ID: "a"}, "recruiter",
+		rebac.Subject{Object: rebac.Object{Type: "user", ID: "alice"}})
+	assert.ErrorIs(t, err, rebac.ErrMaxDepth)
+}
@@ -0,0 +1,139 @@
+package rebac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Schema maps each object type to its relations.
+type Schema map[string]Namespace
+
+// Namespace maps relation names to their definitions.
+type Namespace map[string]Relation
+
+// Relation is the union of its usersets: a subject has the relation if it is
+// in any of them. A relation with no usersets holds only its own tuples.
+type Relation struct {
+	Union []Userset `json:"union,omitempty"`
+}
+
+// Userset is one userset rewrite rule; exactly one field is set.
+type Userset struct {
+	// This is the subjects of tuples written for the relation itself.
+	This bool `json:"this,omitempty"`
+	// ComputedUserset is the subjects of another relation on the same
+	// object, e.g. every owner is also an editor.
+	ComputedUserset string `json:"computedUserset,omitempty"`
+	// TupleToUserset follows the objects related through Tupleset and takes
+	// the subjects of ComputedUserset on each of them, e.g. the recruiters of
+	// the company a job belongs to.
+	TupleToUserset *TupleToUserset `json:"tupleToUserset,omitempty"`
+}
+
+// TupleToUserset is the rewrite described on Userset.
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computedUserset"`
+}
+
+// usersets returns the rewrite rules of a relation, defaulting to This.
+func (r Relation) usersets() []Userset {
+	if len(r.Union) == 0 {
+		return []Userset{{This: true}}
+	}
+	return r.Union
+}
+
+// DefaultSchema models the job board: companies have owners and recruiters,
+// and each job belongs to a company.
+//
+//	company: owner; recruiter = this | owner
+//	job:     company; owner = this | company->owner
+//	         editor = this | owner | company->recruiter
+//	         viewer = this | editor
+var DefaultSchema = Schema{
+	"user": {},
+	"company": {
+		"owner":     {},
+		"recruiter": {Union: []Userset{{This: true}, {ComputedUserset: "owner"}}},
+	},
+	"job": {
+		"company": {},
+		"owner": {Union: []Userset{
+			{This: true},
+			{TupleToUserset: &TupleToUserset{Tupleset: "company", ComputedUserset: "owner"}},
+		}},
+		"editor": {Union: []Userset{
+			{This: true},
+			{ComputedUserset: "owner"},
+			{TupleToUserset: &TupleToUserset{Tupleset: "company", ComputedUserset: "recruiter"}},
+		}},
+		"viewer": {Union: []Userset{{This: true}, {ComputedUserset: "editor"}}},
+	},
+}
+
+// LoadSchema reads a JSON schema from path, checking that every rewrite
+// refers to a relation that exists.
+func LoadSchema(path string) (Schema, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return schema, schema.validate()
+}
+
+// SchemaFromEnv returns the schema in the file named by REBAC_SCHEMA_FILE, or
+// DefaultSchema when it is not set.
+func SchemaFromEnv() (Schema, error) {
+	if path := os.Getenv("REBAC_SCHEMA_FILE"); path != "" {
+		return LoadSchema(path)
+	}
+	return DefaultSchema, nil
+}
+
+func (s Schema) validate() error {
+	for typ, namespace := range s {
+		for name, relation := range namespace {
+			for _, u := range relation.Union {
+				switch {
+				case u.ComputedUserset != "":
+					if _, ok := namespace[u.ComputedUserset]; !ok {
+						return fmt.Errorf("%s#%s: unknown relation %q", typ, name, u.ComputedUserset)
+					}
+				case u.TupleToUserset != nil:
+					if _, ok := namespace[u.TupleToUserset.Tupleset]; !ok {
+						return fmt.Errorf("%s#%s: unknown tupleset %q", typ, name, u.TupleToUserset.Tupleset)
+					}
+				case !u.This:
+					return fmt.Errorf("%s#%s: empty userset rewrite", typ, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateTuple checks that a tuple's object relation and any subject
+// relation are defined.
+func (s Schema) ValidateTuple(t Tuple) error {
+	if !s.hasRelation(t.Object.Type, t.Relation) {
+		return fmt.Errorf("%s has no relation %q", t.Object.Type, t.Relation)
+	}
+	if _, ok := s[t.Subject.Type]; !ok {
+		return fmt.Errorf("unknown subject type %q", t.Subject.Type)
+	}
+	if t.Subject.Relation != "" && !s.hasRelation(t.Subject.Type, t.Subject.Relation) {
+		return fmt.Errorf("%s has no relation %q", t.Subject.Type, t.Subject.Relation)
+	}
+	return nil
+}
+
+func (s Schema) hasRelation(typ, relation string) bool {
+	_, ok := s[typ][relation]
+	return ok
+}
@@ -0,0 +1,103 @@
+package rebac
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// Store holds relation tuples.
+type Store interface {
+	Write(t Tuple) error
+	// Delete removes a tuple and reports whether it existed.
+	Delete(t Tuple) (bool, error)
+	// Subjects lists the subjects related to object through relation.
+	Subjects(object Object, relation string) ([]Subject, error)
+}
+
+// MemoryStore is a process-local Store, for tests and development.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tuples map[Tuple]struct{}
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tuples: make(map[Tuple]struct{})}
+}
+
+func (m *MemoryStore) Write(t Tuple) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tuples[t] = struct{}{}
+	return nil
+}
+
+func (m *MemoryStore) Delete(t Tuple) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.tuples[t]
+	delete(m.tuples, t)
+	return ok, nil
+}
+
+func (m *MemoryStore) Subjects(object Object, relation string) ([]Subject, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var subjects []Subject
+	for t := range m.tuples {
+		if t.Object == object && t.Relation == relation {
+			subjects = append(subjects, t.Subject)
+		}
+	}
+	return subjects, nil
+}
+
+// SQLStore keeps tuples in the relation_tuples table.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+// NewSQLStore returns a store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+func (s *SQLStore) Write(t Tuple) error {
+	_, err := s.DB.Exec(`INSERT INTO relation_tuples
+		(object_type, object_id, relation, subject_type, subject_id, subject_relation)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING`,
+		t.Object.Type, t.Object.ID, t.Relation, t.Subject.Type, t.Subject.ID, t.Subject.Relation)
+	return err
+}
+
+func (s *SQLStore) Delete(t Tuple) (bool, error) {
+	res, err := s.DB.Exec(`DELETE FROM relation_tuples
+		WHERE object_type = $1 AND object_id = $2 AND relation = $3
+		AND subject_type = $4 AND subject_id = $5 AND subject_relation = $6`,
+		t.Object.Type, t.Object.ID, t.Relation, t.Subject.Type, t.Subject.ID, t.Subject.Relation)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *SQLStore) Subjects(object Object, relation string) ([]Subject, error) {
+	rows, err := s.DB.Query(`SELECT subject_type, subject_id, subject_relation FROM relation_tuples
+		WHERE object_type = $1 AND object_id = $2 AND relation = $3`,
+		object.Type, object.ID, relation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subjects []Subject
+	for rows.Next() {
+		var s Subject
+		if err := rows.Scan(&s.Type, &s.ID, &s.Relation); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, s)
+	}
+	return subjects, rows.Err()
+}
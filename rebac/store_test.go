@@ -0,0 +1,39 @@
+package rebac_test
+
+import (
+	"auth-service/rebac"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStore(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+	store := rebac.NewSQLStore(mockDB)
+	tuple := mustTuple(t, "job:42", "editor", "company:7#recruiter")
+
+	mock.ExpectExec("INSERT INTO relation_tuples").
+		WithArgs("job", "42", "editor", "company", "7", "recruiter").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT subject_type, subject_id, subject_relation FROM relation_tuples").
+		WithArgs("job", "42", "editor").
+		WillReturnRows(sqlmock.NewRows([]string{"subject_type", "subject_id", "subject_relation"}).
+			AddRow("company", "7", "recruiter").
+			AddRow("user", "alice", ""))
+	mock.ExpectExec("DELETE FROM relation_tuples").
+		WithArgs("job", "42", "editor", "company", "7", "recruiter").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, store.Write(tuple))
+	subjects, err := store.Subjects(tuple.Object, "editor")
+	require.NoError(t, err)
+	assert.Equal(t, []rebac.Subject{tuple.Subject, {Object: rebac.Object{Type: "user", ID: "alice"}}}, subjects)
+	deleted, err := store.Delete(tuple)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
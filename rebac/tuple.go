@@ -0,0 +1,70 @@
+// Package rebac implements relationship-based access control in the style of
+// Google's Zanzibar. Relationships are stored as (object, relation, subject)
+// tuples, such as job:42#company@company:7 or company:7#recruiter@user:alice,
+// and a Schema of userset rewrites says how relations imply one another, so
+// that "alice may edit job 42" can follow from her being a recruiter in the
+// company that owns it.
+package rebac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Object is a typed object, written type:id, e.g. job:42.
+type Object struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func (o Object) String() string {
+	return o.Type + ":" + o.ID
+}
+
+// Subject is either a concrete object such as user:alice, or, when Relation
+// is set, the userset of everything with that relation to the object, written
+// company:7#recruiter.
+type Subject struct {
+	Object
+	Relation string `json:"relation,omitempty"`
+}
+
+func (s Subject) String() string {
+	if s.Relation != "" {
+		return s.Object.String() + "#" + s.Relation
+	}
+	return s.Object.String()
+}
+
+// Tuple states that Subject has Relation to Object.
+type Tuple struct {
+	Object   Object  `json:"object"`
+	Relation string  `json:"relation"`
+	Subject  Subject `json:"subject"`
+}
+
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ParseObject parses type:id. IDs may themselves contain colons.
+func ParseObject(s string) (Object, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || typ == "" || id == "" || strings.Contains(s, "#") {
+		return Object{}, fmt.Errorf("invalid object %q, want type:id", s)
+	}
+	return Object{Type: typ, ID: id}, nil
+}
+
+// ParseSubject parses type:id or type:id#relation.
+func ParseSubject(s string) (Subject, error) {
+	objectPart, relation, hasRelation := strings.Cut(s, "#")
+	if hasRelation && relation == "" {
+		return Subject{}, fmt.Errorf("invalid subject %q, want type:id or type:id#relation", s)
+	}
+	object, err := ParseObject(objectPart)
+	if err != nil {
+		return Subject{}, fmt.Errorf("invalid subject %q, want type:id or type:id#relation", s)
+	}
+	return Subject{Object: object, Relation: relation}, nil
+}
@@ -0,0 +1,59 @@
+package rebac_test
+
+import (
+	"auth-service/rebac"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubject(t *testing.T) {
+	s, err := rebac.ParseSubject("company:7#recruiter")
+	require.NoError(t, err)
+	assert.Equal(t, rebac.Subject{Object: rebac.Object{Type: "company", ID: "7"}, Relation: "recruiter"}, s)
+	assert.Equal(t, "company:7#recruiter", s.String())
+
+	s, err = rebac.ParseSubject("user:urn:alice")
+	require.NoError(t, err)
+	assert.Equal(t, "urn:alice", s.ID)
+
+	for _, bad := range []string{"", "user", "user:", ":1", "company:7#", "company#recruiter"} {
+		_, err := rebac.ParseSubject(bad)
+		assert.Error(t, err, bad)
+	}
+	_, err = rebac.ParseObject("company:7#recruiter")
+	assert.Error(t, err)
+}
+
+func TestValidateTuple(t *testing.T) {
+	schema := rebac.DefaultSchema
+	assert.NoError(t, schema.ValidateTuple(mustTuple(t, "job:42", "company", "company:7")))
+	assert.NoError(t, schema.ValidateTuple(mustTuple(t, "job:42", "editor", "company:7#recruiter")))
+	assert.Error(t, schema.ValidateTuple(mustTuple(t, "job:42", "publisher", "user:alice")))
+	assert.Error(t, schema.ValidateTuple(mustTuple(t, "invoice:1", "owner", "user:alice")))
+	assert.Error(t, schema.ValidateTuple(mustTuple(t, "job:42", "editor", "team:1")))
+	assert.Error(t, schema.ValidateTuple(mustTuple(t, "job:42", "editor", "company:7#member")))
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	require.NoError(t, os.WriteFile(good, []byte(`{
+		"user": {},
+		"doc": {
+			"owner": {},
+			"reader": {"union": [{"this": true}, {"computedUserset": "owner"}]}
+		}
+	}`), 0o600))
+	schema, err := rebac.LoadSchema(good)
+	require.NoError(t, err)
+	assert.Len(t, schema["doc"]["reader"].Union, 2)
+
+	bad := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(bad, []byte(`{"doc": {"reader": {"union": [{"computedUserset": "owner"}]}}}`), 0o600))
+	_, err = rebac.LoadSchema(bad)
+	assert.ErrorContains(t, err, `unknown relation "owner"`)
+}
@@ -3,18 +3,74 @@ package routes
 
 import (
 	"auth-service/handlers"
+	"auth-service/middleware"
+	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
+// requires restricts a handler to authenticated callers holding a permission.
+func requires(resource, action string, h http.HandlerFunc) http.Handler {
+	return middleware.AuthMiddleware(middleware.RequirePermission(resource, action)(h))
+}
+
 func SetupRoutes() *mux.Router {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/register", handlers.RegisterHandler).Methods("POST")
 	router.HandleFunc("/login", handlers.LoginHandler).Methods("POST")
 	router.HandleFunc("/logout", handlers.LogoutHandler).Methods("POST")
+	router.HandleFunc("/email/verify", handlers.VerifyEmailHandler).Methods("POST")
+	router.HandleFunc("/email/verify/resend", handlers.ResendVerificationHandler).Methods("POST")
+	router.HandleFunc("/password/forgot", handlers.ForgotPasswordHandler).Methods("POST")
+	router.HandleFunc("/password/reset", handlers.ResetPasswordHandler).Methods("POST")
+	router.Handle("/logout/all", middleware.AuthMiddleware(
+		http.HandlerFunc(handlers.LogoutAllHandler))).Methods("POST")
+	router.HandleFunc("/token/refresh", handlers.RefreshHandler).Methods("POST")
+	router.HandleFunc("/token/exchange", handlers.TokenExchangeHandler).Methods("POST")
+	router.HandleFunc("/introspect", handlers.IntrospectHandler).Methods("POST")
+	router.HandleFunc("/authorize", handlers.AuthorizeHandler).Methods("POST")
+	router.HandleFunc("/relations", handlers.WriteRelationHandler).Methods("POST")
+	router.HandleFunc("/relations", handlers.DeleteRelationHandler).Methods("DELETE")
+	router.HandleFunc("/relations/check", handlers.CheckRelationHandler).Methods("POST")
+	router.Handle("/me", middleware.AuthMiddleware(
+		http.HandlerFunc(handlers.GetMeHandler))).Methods("GET")
+	router.Handle("/me", middleware.AuthMiddleware(
+		http.HandlerFunc(handlers.UpdateMeHandler))).Methods("PATCH")
+	router.Handle("/me", middleware.AuthMiddleware(
+		http.HandlerFunc(handlers.DeleteMeHandler))).Methods("DELETE")
+	router.Handle("/me/export", middleware.AuthMiddleware(
+		http.HandlerFunc(handlers.ExportMeHandler))).Methods("GET")
+	router.Handle("/me/password", middleware.AuthMiddleware(
+		http.HandlerFunc(handlers.ChangePasswordHandler))).Methods("POST")
 	router.Handle("/authenticate", middleware.AuthMiddleware(
-			http.HandlerFunc(handlers.AuthenticateHandler)))
+		http.HandlerFunc(handlers.AuthenticateHandler)))
+	router.Handle("/admin/users/{id}/revoke-sessions", requires("session", "revoke", handlers.AdminRevokeSessionsHandler)).Methods("POST")
+	router.Handle("/admin/users/{id}/roles", requires("role", "manage", handlers.AddUserRoleHandler)).Methods("POST")
+	router.Handle("/admin/users/{id}/roles/{roleId}", requires("role", "manage", handlers.RemoveUserRoleHandler)).Methods("DELETE")
+	router.Handle("/admin/roles", requires("role", "manage", handlers.ListRolesHandler)).Methods("GET")
+	router.Handle("/admin/roles", requires("role", "manage", handlers.CreateRoleHandler)).Methods("POST")
+	router.Handle("/admin/roles/{id}", requires("role", "manage", handlers.GetRoleHandler)).Methods("GET")
+	router.Handle("/admin/roles/{id}", requires("role", "manage", handlers.UpdateRoleHandler)).Methods("PUT")
+	router.Handle("/admin/roles/{id}", requires("role", "manage", handlers.DeleteRoleHandler)).Methods("DELETE")
+	router.Handle("/admin/roles/{id}/permissions", requires("role", "manage", handlers.ListRolePermissionsHandler)).Methods("GET")
+	router.Handle("/admin/roles/{id}/permissions", requires("role", "manage", handlers.AddRolePermissionHandler)).Methods("POST")
+	router.Handle("/admin/roles/{id}/permissions/{permissionId}", requires("role", "manage", handlers.RemoveRolePermissionHandler)).Methods("DELETE")
+	router.Handle("/admin/groups", requires("group", "manage", handlers.ListGroupsHandler)).Methods("GET")
+	router.Handle("/admin/groups", requires("group", "manage", handlers.CreateGroupHandler)).Methods("POST")
+	router.Handle("/admin/groups/{id}", requires("group", "manage", handlers.GetGroupHandler)).Methods("GET")
+	router.Handle("/admin/groups/{id}", requires("group", "manage", handlers.UpdateGroupHandler)).Methods("PUT")
+	router.Handle("/admin/groups/{id}", requires("group", "manage", handlers.DeleteGroupHandler)).Methods("DELETE")
+	router.Handle("/admin/groups/{id}/members", requires("group", "manage", handlers.AddGroupMemberHandler)).Methods("POST")
+	router.Handle("/admin/groups/{id}/members/{userId}", requires("group", "manage", handlers.RemoveGroupMemberHandler)).Methods("DELETE")
+	router.Handle("/admin/groups/{id}/roles", requires("group", "manage", handlers.AddGroupRoleHandler)).Methods("POST")
+	router.Handle("/admin/groups/{id}/roles/{roleId}", requires("group", "manage", handlers.RemoveGroupRoleHandler)).Methods("DELETE")
+	router.Handle("/admin/permissions", requires("permission", "manage", handlers.ListPermissionsHandler)).Methods("GET")
+	router.Handle("/admin/permissions", requires("permission", "manage", handlers.CreatePermissionHandler)).Methods("POST")
+	router.Handle("/admin/permissions/{id}", requires("permission", "manage", handlers.GetPermissionHandler)).Methods("GET")
+	router.Handle("/admin/permissions/{id}", requires("permission", "manage", handlers.UpdatePermissionHandler)).Methods("PUT")
+	router.Handle("/admin/permissions/{id}", requires("permission", "manage", handlers.DeletePermissionHandler)).Methods("DELETE")
+	router.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler).Methods("GET")
 	router.HandleFunc("/health", handlers.HealthHandler).Methods("GET")
 	return router
 }
@@ -20,7 +20,50 @@ func TestSetupRoutes(t *testing.T) {
 		{"POST", "/register"},
 		{"POST", "/login"},
 		{"POST", "/logout"},
+		{"POST", "/logout/all"},
+		{"POST", "/email/verify"},
+		{"POST", "/email/verify/resend"},
+		{"POST", "/password/forgot"},
+		{"POST", "/password/reset"},
+		{"GET", "/me"},
+		{"PATCH", "/me"},
+		{"DELETE", "/me"},
+		{"GET", "/me/export"},
+		{"POST", "/me/password"},
+		{"POST", "/token/refresh"},
+		{"POST", "/token/exchange"},
+		{"POST", "/introspect"},
+		{"POST", "/authorize"},
+		{"POST", "/relations"},
+		{"DELETE", "/relations"},
+		{"POST", "/relations/check"},
 		{"GET", "/authenticate"},
+		{"POST", "/admin/users/1/revoke-sessions"},
+		{"POST", "/admin/users/1/roles"},
+		{"DELETE", "/admin/users/1/roles/2"},
+		{"GET", "/admin/roles"},
+		{"POST", "/admin/roles"},
+		{"GET", "/admin/roles/1"},
+		{"PUT", "/admin/roles/1"},
+		{"DELETE", "/admin/roles/1"},
+		{"GET", "/admin/roles/1/permissions"},
+		{"POST", "/admin/roles/1/permissions"},
+		{"DELETE", "/admin/roles/1/permissions/2"},
+		{"GET", "/admin/groups"},
+		{"POST", "/admin/groups"},
+		{"GET", "/admin/groups/1"},
+		{"PUT", "/admin/groups/1"},
+		{"DELETE", "/admin/groups/1"},
+		{"POST", "/admin/groups/1/members"},
+		{"DELETE", "/admin/groups/1/members/2"},
+		{"POST", "/admin/groups/1/roles"},
+		{"DELETE", "/admin/groups/1/roles/2"},
+		{"GET", "/admin/permissions"},
+		{"POST", "/admin/permissions"},
+		{"GET", "/admin/permissions/1"},
+		{"PUT", "/admin/permissions/1"},
+		{"DELETE", "/admin/permissions/1"},
+		{"GET", "/.well-known/jwks.json"},
 		{"GET", "/health"},
 	}
 
@@ -0,0 +1,124 @@
+package jwt
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned by ValidateToken for tokens whose jti has been
+// placed on the denylist.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// Denylist records revoked token IDs (jti) until the tokens would have expired
+// on their own, after which the entries can be purged.
+type Denylist interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+	Purge(now time.Time) error
+}
+
+var (
+	denylistMu sync.RWMutex
+	denylist   Denylist = NewMemoryDenylist()
+)
+
+// SetDenylist replaces the denylist consulted by ValidateToken. The default is
+// an in-memory denylist, which is only correct for a single instance.
+func SetDenylist(d Denylist) {
+	denylistMu.Lock()
+	defer denylistMu.Unlock()
+	denylist = d
+}
+
+func currentDenylist() Denylist {
+	denylistMu.RLock()
+	defer denylistMu.RUnlock()
+	return denylist
+}
+
+// RevokeToken places the token described by claims on the denylist until it
+// expires.
+func RevokeToken(claims *Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no jti")
+	}
+	expiresAt := time.Now().Add(AccessTokenTTL())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return currentDenylist().Revoke(claims.ID, expiresAt)
+}
+
+// StartDenylistSweeper purges expired denylist entries every interval until
+// the returned stop function is called.
+func StartDenylistSweeper(interval time.Duration) (stop func()) {
+	return StartSweeper("token denylist", interval, func(now time.Time) error {
+		return currentDenylist().Purge(now)
+	})
+}
+
+// MemoryDenylist is a process-local Denylist.
+type MemoryDenylist struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+// NewMemoryDenylist returns an empty in-memory denylist.
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: make(map[string]time.Time)}
+}
+
+func (m *MemoryDenylist) Revoke(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[jti] = expiresAt
+	return nil
+}
+
+func (m *MemoryDenylist) IsRevoked(jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.entries[jti]
+	return ok, nil
+}
+
+func (m *MemoryDenylist) Purge(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for jti, expiresAt := range m.entries {
+		if !expiresAt.After(now) {
+			delete(m.entries, jti)
+		}
+	}
+	return nil
+}
+
+// SQLDenylist stores revoked token IDs in the revoked_tokens table so that
+// every instance of the service sees the same revocations.
+type SQLDenylist struct {
+	DB *sql.DB
+}
+
+// NewSQLDenylist returns a Denylist backed by the given database.
+func NewSQLDenylist(db *sql.DB) *SQLDenylist {
+	return &SQLDenylist{DB: db}
+}
+
+func (s *SQLDenylist) Revoke(jti string, expiresAt time.Time) error {
+	_, err := s.DB.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+func (s *SQLDenylist) IsRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := s.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)", jti).Scan(&revoked)
+	return revoked, err
+}
+
+func (s *SQLDenylist) Purge(now time.Time) error {
+	_, err := s.DB.Exec("DELETE FROM revoked_tokens WHERE expires_at <= $1", now)
+	return err
+}
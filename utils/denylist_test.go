@@ -0,0 +1,60 @@
+package jwt_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTokenSetsUniqueJTI(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+
+	first, err := jwt.GenerateToken("testuser", "employer")
+	assert.NoError(t, err)
+	second, err := jwt.GenerateToken("testuser", "employer")
+	assert.NoError(t, err)
+
+	a, _, err := jwt.ValidateToken(first)
+	assert.NoError(t, err)
+	b, _, err := jwt.ValidateToken(second)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, a.ID)
+	assert.NotEqual(t, a.ID, b.ID)
+}
+
+func TestRevokedTokenIsRejected(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	jwt.SetDenylist(jwt.NewMemoryDenylist())
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	assert.NoError(t, err)
+	claims, _, err := jwt.ValidateToken(token)
+	assert.NoError(t, err)
+
+	assert.NoError(t, jwt.RevokeToken(claims))
+
+	claims, expired, err := jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+	assert.False(t, expired)
+	assert.Nil(t, claims)
+}
+
+func TestMemoryDenylistPurge(t *testing.T) {
+	d := jwt.NewMemoryDenylist()
+	now := time.Now()
+	assert.NoError(t, d.Revoke("old", now.Add(-time.Minute)))
+	assert.NoError(t, d.Revoke("live", now.Add(time.Hour)))
+
+	assert.NoError(t, d.Purge(now))
+
+	revoked, _ := d.IsRevoked("old")
+	assert.False(t, revoked)
+	revoked, _ = d.IsRevoked("live")
+	assert.True(t, revoked)
+}
@@ -0,0 +1,196 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Errors returned by VerifyDPoPProof.
+var (
+	ErrDPoPProofInvalid = errors.New("DPoP proof is invalid")
+	ErrDPoPProofReplay  = errors.New("DPoP proof has already been used")
+)
+
+// dpopAlgorithms are the proof signing algorithms we accept. Proofs are always
+// signed with the client's private key, so symmetric algorithms make no sense.
+var dpopAlgorithms = []string{"ES256", "RS256", "EdDSA"}
+
+// Confirmation is the RFC 7800 "cnf" claim. JKT binds a token to the JWK
+// thumbprint of a DPoP key (RFC 9449 section 6).
+type Confirmation struct {
+	JKT string `json:"jkt,omitempty"`
+}
+
+// DPoPProof holds the verified parts of a DPoP proof JWT.
+type DPoPProof struct {
+	JKT      string
+	ID       string
+	IssuedAt time.Time
+}
+
+type dpopClaims struct {
+	HTM string `json:"htm"`
+	HTU string `json:"htu"`
+	ATH string `json:"ath,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// DPoPProofMaxAge is how far a proof's iat may be from the current time,
+// read from DPOP_PROOF_MAX_AGE as a Go duration. It defaults to 5 minutes.
+func DPoPProofMaxAge() time.Duration {
+	if age, err := time.ParseDuration(os.Getenv("DPOP_PROOF_MAX_AGE")); err == nil && age > 0 {
+		return age
+	}
+	return 5 * time.Minute
+}
+
+// VerifyDPoPProof checks a DPoP proof JWT (RFC 9449 section 4.3) for a request
+// with the given method and URL. When accessToken is non-empty the proof must
+// also carry its hash in "ath". Each proof jti is accepted only once.
+func VerifyDPoPProof(proof, method, url, accessToken string) (*DPoPProof, error) {
+	if proof == "" {
+		return nil, fmt.Errorf("%w: missing DPoP header", ErrDPoPProofInvalid)
+	}
+
+	var jkt string
+	claims := &dpopClaims{}
+	parser := jwt.Parser{ValidMethods: dpopAlgorithms, SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(proof, claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, fmt.Errorf("typ must be dpop+jwt")
+		}
+		raw, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		var jwk JWK
+		if err := json.Unmarshal(raw, &jwk); err != nil || jwk.Kty == "" {
+			return nil, fmt.Errorf("jwk header is missing")
+		}
+		if jkt, err = Thumbprint(jwk); err != nil {
+			return nil, err
+		}
+		return JWKToPublicKey(jwk)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDPoPProofInvalid, err)
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: jti and iat are required", ErrDPoPProofInvalid)
+	}
+	if claims.HTM != method {
+		return nil, fmt.Errorf("%w: htm does not match", ErrDPoPProofInvalid)
+	}
+	if normalizeHTU(claims.HTU) != normalizeHTU(url) {
+		return nil, fmt.Errorf("%w: htu does not match", ErrDPoPProofInvalid)
+	}
+	now := time.Now()
+	if age := now.Sub(claims.IssuedAt.Time); age > DPoPProofMaxAge() || -age > DPoPProofMaxAge() {
+		return nil, fmt.Errorf("%w: iat is outside the acceptable window", ErrDPoPProofInvalid)
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if claims.ATH != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return nil, fmt.Errorf("%w: ath does not match the access token", ErrDPoPProofInvalid)
+		}
+	}
+
+	// Proofs are replayable for as long as their iat is acceptable, so the
+	// jti is remembered for twice the window.
+	if !dpopReplayCache.add(jkt+":"+claims.ID, claims.IssuedAt.Add(2*DPoPProofMaxAge())) {
+		return nil, ErrDPoPProofReplay
+	}
+
+	return &DPoPProof{JKT: jkt, ID: claims.ID, IssuedAt: claims.IssuedAt.Time}, nil
+}
+
+// normalizeHTU drops the query and fragment, which are not part of htu.
+func normalizeHTU(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	return u
+}
+
+// RequestURL reconstructs the URL a client used to reach r, for comparison
+// with a proof's htu. PUBLIC_BASE_URL should be set when the service sits
+// behind a proxy that rewrites the host or path prefix. X-Forwarded-Proto is
+// only honoured when TRUSTED_PROXY is true, since any client can send it.
+func RequestURL(r *http.Request) string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/") + r.URL.Path
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if trusted, _ := strconv.ParseBool(os.Getenv("TRUSTED_PROXY")); trusted {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			scheme = proto
+		}
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// AuthorizationToken splits an Authorization header into its scheme
+// ("Bearer" or "DPoP") and token. A bare token is treated as Bearer.
+func AuthorizationToken(header string) (scheme, token string) {
+	if i := strings.IndexByte(header, ' '); i > 0 {
+		scheme = header[:i]
+		if strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "DPoP") {
+			return scheme, strings.TrimSpace(header[i+1:])
+		}
+	}
+	return "Bearer", header
+}
+
+// replayCache remembers DPoP proof IDs until they could no longer be accepted.
+// It is process-local; a proof replayed against a different instance within
+// the iat window is not detected.
+type replayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var dpopReplayCache = &replayCache{seen: make(map[string]time.Time)}
+
+// add records id and reports whether it was new.
+func (c *replayCache) add(id string, until time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seen[id]; ok {
+		return false
+	}
+	c.seen[id] = until
+	return true
+}
+
+func (c *replayCache) purge(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, until := range c.seen {
+		if now.After(until) {
+			delete(c.seen, id)
+		}
+	}
+}
+
+// StartDPoPReplaySweeper drops remembered proof IDs once they have aged out.
+func StartDPoPReplaySweeper(interval time.Duration) (stop func()) {
+	return StartSweeper("DPoP replay cache", interval, func(now time.Time) error {
+		dpopReplayCache.purge(now)
+		return nil
+	})
+}
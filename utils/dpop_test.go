@@ -0,0 +1,78 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"auth-service/internal/testutil"
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDPoPProof(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk, _ := jwt.PublicKeyToJWK(&key.PublicKey)
+	expectedJKT, _ := jwt.Thumbprint(jwk)
+
+	proof := testutil.DPoPProof(t, key, "GET", "https://auth.example.com/me", "access-token", time.Now())
+	verified, err := jwt.VerifyDPoPProof(proof, "GET", "https://auth.example.com/me?x=1", "access-token")
+	require.NoError(t, err)
+	assert.Equal(t, expectedJKT, verified.JKT)
+
+	// The same proof cannot be used twice.
+	_, err = jwt.VerifyDPoPProof(proof, "GET", "https://auth.example.com/me", "access-token")
+	assert.ErrorIs(t, err, jwt.ErrDPoPProofReplay)
+}
+
+func TestVerifyDPoPProofRejections(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	url := "https://auth.example.com/me"
+
+	cases := map[string]struct {
+		proof  string
+		method string
+		token  string
+	}{
+		"wrong method":       {testutil.DPoPProof(t, key, "POST", url, "tok", time.Now()), "GET", "tok"},
+		"wrong access token": {testutil.DPoPProof(t, key, "GET", url, "tok", time.Now()), "GET", "other"},
+		"missing ath":        {testutil.DPoPProof(t, key, "GET", url, "", time.Now()), "GET", "tok"},
+		"stale iat":          {testutil.DPoPProof(t, key, "GET", url, "tok", time.Now().Add(-time.Hour)), "GET", "tok"},
+		"not a proof":        {"garbage", "GET", "tok"},
+		"missing":            {"", "GET", "tok"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := jwt.VerifyDPoPProof(tc.proof, tc.method, url, tc.token)
+			assert.ErrorIs(t, err, jwt.ErrDPoPProofInvalid)
+		})
+	}
+}
+
+func TestAuthorizationToken(t *testing.T) {
+	scheme, token := jwt.AuthorizationToken("DPoP abc")
+	assert.Equal(t, "DPoP", scheme)
+	assert.Equal(t, "abc", token)
+
+	scheme, token = jwt.AuthorizationToken("Bearer abc")
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, "abc", token)
+}
+
+func TestRequestURLForwardedProto(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/me", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, "http://example.com/me", jwt.RequestURL(req))
+
+	os.Setenv("TRUSTED_PROXY", "true")
+	defer os.Unsetenv("TRUSTED_PROXY")
+	assert.Equal(t, "https://example.com/me", jwt.RequestURL(req))
+}
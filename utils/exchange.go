@@ -0,0 +1,67 @@
+package jwt
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrScopeNotAllowed is returned when a token exchange asks for a scope that
+// the subject token does not carry.
+var ErrScopeNotAllowed = errors.New("requested scope exceeds the subject token's scope")
+
+// ExchangeTokenTTL is the maximum lifetime of tokens minted by token exchange,
+// read from JWT_EXCHANGE_TTL as a Go duration. It defaults to 5 minutes.
+func ExchangeTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_EXCHANGE_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 5 * time.Minute
+}
+
+// ExchangeToken mints a downscoped token for subject, addressed to audience and
+// acting on behalf of actor (RFC 8693). The new token never outlives the
+// subject token, and scope must be a subset of the subject's scope when the
+// subject token is itself scoped. A DPoP-bound subject yields a token bound to
+// the same key. It returns the token and its lifetime.
+func ExchangeToken(subject *Claims, actor, audience, scope string) (string, time.Duration, error) {
+	if subject.Scope != "" {
+		granted := strings.Fields(subject.Scope)
+		for _, s := range strings.Fields(scope) {
+			if !containsString(granted, s) {
+				return "", 0, ErrScopeNotAllowed
+			}
+		}
+		if scope == "" {
+			scope = subject.Scope
+		}
+	}
+
+	ttl := ExchangeTokenTTL()
+	if subject.ExpiresAt != nil {
+		if remaining := time.Until(subject.ExpiresAt.Time); remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	claims := &Claims{
+		Username:     subject.Username,
+		Role:         subject.Role,
+		Roles:        subject.Roles,
+		Scope:        strings.Join(strings.Fields(scope), " "),
+		Act:          &Actor{Subject: actor, Act: subject.Act},
+		TokenVersion: subject.TokenVersion,
+		Cnf:          subject.Cnf,
+	}
+	claims.Subject = subject.Subject
+	claims.Audience = jwt.ClaimStrings{audience}
+
+	token, err := IssueToken(claims, ttl)
+	if err != nil {
+		return "", 0, err
+	}
+	return token, ttl, nil
+}
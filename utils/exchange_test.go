@@ -0,0 +1,62 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeToken(t *testing.T) {
+	setValidationEnv()
+
+	userToken, err := jwt.GenerateTokenForAudience("testuser", "jobseeker", "jobs-api")
+	require.NoError(t, err)
+	subject, _, err := jwt.ValidateToken(userToken, "jobs-api")
+	require.NoError(t, err)
+
+	token, ttl, err := jwt.ExchangeToken(subject, "jobs-service", "applications-api", "applications:read")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, ttl, jwt.ExchangeTokenTTL())
+
+	claims, _, err := jwt.ValidateToken(token, "applications-api")
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", claims.Subject)
+	assert.Equal(t, "applications:read", claims.Scope)
+	require.NotNil(t, claims.Act)
+	assert.Equal(t, "jobs-service", claims.Act.Subject)
+
+	// The exchanged token cannot be replayed against the original audience.
+	_, _, err = jwt.ValidateToken(token, "jobs-api")
+	assert.ErrorIs(t, err, jwt.ErrInvalidAudience)
+
+	// Exchanging again narrows further and records the delegation chain.
+	again, _, err := jwt.ExchangeToken(claims, "applications-service", "messaging-api", "")
+	require.NoError(t, err)
+	chained, _, err := jwt.ValidateToken(again, "messaging-api")
+	require.NoError(t, err)
+	assert.Equal(t, "applications:read", chained.Scope)
+	assert.Equal(t, "applications-service", chained.Act.Subject)
+	assert.Equal(t, "jobs-service", chained.Act.Act.Subject)
+
+	_, _, err = jwt.ExchangeToken(claims, "applications-service", "messaging-api", "applications:write")
+	assert.ErrorIs(t, err, jwt.ErrScopeNotAllowed)
+}
+
+func TestExchangeTokenNeverOutlivesSubject(t *testing.T) {
+	setValidationEnv()
+
+	claims := &jwt.Claims{Username: "testuser"}
+	claims.Subject = "testuser"
+	userToken, err := jwt.IssueToken(claims, time.Minute)
+	require.NoError(t, err)
+	subject, _, err := jwt.ValidateToken(userToken)
+	require.NoError(t, err)
+
+	_, ttl, err := jwt.ExchangeToken(subject, "jobs-service", "applications-api", "")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, ttl, time.Minute)
+}
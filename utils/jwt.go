@@ -4,18 +4,46 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-// Claims defines the custom JWT claims, including a Role field.
+// Claims defines the custom JWT claims. Roles lists every role the user holds;
+// Role repeats the first of them for consumers that predate multiple roles.
+// Scope is a space-separated list (RFC 6749) and is empty for full user
+// tokens. Act is set on tokens obtained through token exchange and names the
+// party acting on the subject's behalf.
 type Claims struct {
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Roles    []string `json:"roles,omitempty"`
+	Scope    string   `json:"scope,omitempty"`
+	Act      *Actor   `json:"act,omitempty"`
+	// TokenVersion is the user's token_version when the token was minted.
+	// Bumping the stored version invalidates every older token.
+	TokenVersion int `json:"tokenVersion"`
+	// Cnf binds the token to a DPoP key; such tokens are only accepted
+	// together with a proof signed by that key.
+	Cnf *Confirmation `json:"cnf,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Restricted reports whether the token was downscoped or delegated through
+// token exchange. Such tokens only carry part of the user's authority and
+// must not be treated as the user themselves.
+func (c *Claims) Restricted() bool {
+	return c.Act != nil || c.Scope != ""
+}
+
+// Actor is the RFC 8693 "act" claim. Nested actors record a delegation chain,
+// with the most recent actor outermost.
+type Actor struct {
+	Subject string `json:"sub"`
+	Act     *Actor `json:"act,omitempty"`
+}
+
 // getJwtSecret retrieves the JWT secret directly from the environment.
 func getJwtSecret() ([]byte, error) {
 	secret := os.Getenv("JWT_SECRET")
@@ -25,60 +53,128 @@ func getJwtSecret() ([]byte, error) {
 	return []byte(secret), nil
 }
 
-// GenerateToken creates a JWT for the given username and role.
+// GenerateToken creates a JWT for the given username and role, addressed to
+// the default audience (JWT_AUDIENCE).
 func GenerateToken(username, role string) (string, error) {
-	jwtSecret, err := getJwtSecret()
+	return GenerateTokenForAudience(username, role, DefaultAudience())
+}
+
+// GenerateTokenForAudience creates a JWT for the given username and role that
+// is only accepted by the named audience. An empty audience omits the claim.
+func GenerateTokenForAudience(username, role, audience string) (string, error) {
+	return GenerateUserToken(TokenParams{Username: username, Role: role, Audience: audience})
+}
+
+// TokenParams describes the user an access token is minted for. Role defaults
+// to the first of Roles. DPoPJKT, if set, sender-constrains the token to that
+// DPoP key thumbprint.
+type TokenParams struct {
+	Username     string
+	Role         string
+	Roles        []string
+	Audience     string
+	TokenVersion int
+	DPoPJKT      string
+}
+
+// GenerateUserToken creates an access token for the user described by p. It
+// is a JWT, or an opaque reference token when ACCESS_TOKEN_FORMAT=reference.
+func GenerateUserToken(p TokenParams) (string, error) {
+	claims := &Claims{Username: p.Username, Role: p.Role, Roles: p.Roles, TokenVersion: p.TokenVersion}
+	if claims.Role == "" && len(p.Roles) > 0 {
+		claims.Role = p.Roles[0]
+	}
+	claims.Subject = p.Username
+	if p.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{p.Audience}
+	}
+	if p.DPoPJKT != "" {
+		claims.Cnf = &Confirmation{JKT: p.DPoPJKT}
+	}
+	if ReferenceTokensEnabled() {
+		return IssueReferenceToken(claims, AccessTokenTTL())
+	}
+	return IssueToken(claims, AccessTokenTTL())
+}
+
+// IssueToken fills in the registered claims shared by every token this service
+// mints (jti, iat, nbf, exp and iss) and signs claims with the active key.
+// Subject, audience and any custom claims must already be set.
+func IssueToken(claims *Claims, ttl time.Duration) (string, error) {
+	ring, err := currentKeyring()
 	if err != nil {
 		return "", err
 	}
+	key := ring.Active()
 
-	now := time.Now()
-	expirationTime := now.Add(time.Hour * time.Duration(getJwtExpireHours()))
-	issuer := os.Getenv("JWT_ISSUER") // Optionally set via an environment variable
-
-	claims := Claims{
-		Username: username,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			Subject:   username,
-			Issuer:    issuer,
-		},
+	if err := stampClaims(claims, ttl); err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey())
 }
 
-// ValidateToken validates a token and returns its claims, a boolean indicating expiration, and an error if any.
-func ValidateToken(tokenStr string) (*Claims, bool, error) {
-	jwtSecret, err := getJwtSecret()
+// stampClaims sets the registered claims common to every token format.
+func stampClaims(claims *Claims, ttl time.Duration) error {
+	jti, err := GenerateOpaqueToken()
 	if err != nil {
-		return nil, false, err
+		return err
 	}
 
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	now := time.Now()
+	claims.ID = jti
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.Issuer = os.Getenv("JWT_ISSUER") // Optionally set via an environment variable
+	return nil
+}
 
-	if err != nil {
-		// Check if the error is due to expiration.
-		if ve, ok := err.(*jwt.ValidationError); ok {
-			if ve.Errors&jwt.ValidationErrorExpired != 0 {
-				return claims, true, fmt.Errorf("token is expired")
-			}
+// DefaultAudience returns the audience tokens are minted for when the client
+// does not ask for a specific one, read from JWT_AUDIENCE.
+func DefaultAudience() string {
+	return os.Getenv("JWT_AUDIENCE")
+}
+
+// AudienceAllowed reports whether tokens may be minted for audience: either
+// the default audience or one listed in JWT_ALLOWED_AUDIENCES.
+func AudienceAllowed(audience string) bool {
+	if audience == DefaultAudience() {
+		return true
+	}
+	for _, allowed := range splitList(os.Getenv("JWT_ALLOWED_AUDIENCES")) {
+		if allowed == audience {
+			return true
 		}
-		return nil, false, fmt.Errorf("invalid token: %w", err)
 	}
+	return false
+}
 
-	if !token.Valid {
-		return nil, false, fmt.Errorf("token is not valid")
+// splitList splits a comma-separated environment value, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	return claims, false, nil
+// AccessTokenTTL returns the lifetime of access tokens. JWT_ACCESS_TTL takes a
+// Go duration (e.g. "15m"); the legacy JWT_EXPIRE_HOURS is honoured when it is
+// set. Without either, access tokens are short-lived and clients are expected
+// to renew them with a refresh token.
+func AccessTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_ACCESS_TTL")); err == nil {
+		return ttl
+	}
+	if os.Getenv("JWT_EXPIRE_HOURS") != "" {
+		return time.Hour * time.Duration(getJwtExpireHours())
+	}
+	return 15 * time.Minute
 }
 
 func getJwtExpireHours() int {
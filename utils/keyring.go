@@ -0,0 +1,211 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Keyring holds the active signing key plus recently retired keys. New tokens
+// are always signed with the active key; retired keys keep verifying tokens
+// until the grace period after their retirement has passed.
+type Keyring struct {
+	mu      sync.RWMutex
+	active  *SigningKey
+	retired []retiredKey
+}
+
+type retiredKey struct {
+	key       *SigningKey
+	retiredAt time.Time
+}
+
+// NewKeyring returns a keyring whose active key is active.
+func NewKeyring(active *SigningKey) *Keyring {
+	return &Keyring{active: active}
+}
+
+// Active returns the key new tokens are signed with.
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key with the given kid if it is active or was retired
+// less than KeyGracePeriod ago.
+func (k *Keyring) Lookup(kid string, now time.Time) (*SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.active != nil && k.active.ID == kid {
+		return k.active, true
+	}
+	grace := KeyGracePeriod()
+	for _, r := range k.retired {
+		if r.key.ID == kid && now.Before(r.retiredAt.Add(grace)) {
+			return r.key, true
+		}
+	}
+	return nil, false
+}
+
+// VerificationKeys returns the active key followed by every retired key that
+// is still within its grace period.
+func (k *Keyring) VerificationKeys(now time.Time) []*SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := []*SigningKey{}
+	if k.active != nil {
+		keys = append(keys, k.active)
+	}
+	grace := KeyGracePeriod()
+	for _, r := range k.retired {
+		if now.Before(r.retiredAt.Add(grace)) {
+			keys = append(keys, r.key)
+		}
+	}
+	return keys
+}
+
+// Rotate makes next the active key and retires the previous one as of now.
+// Retired keys whose grace period is over are dropped.
+func (k *Keyring) Rotate(next *SigningKey, now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.active != nil {
+		k.retired = append(k.retired, retiredKey{key: k.active, retiredAt: now})
+	}
+	k.active = next
+
+	grace := KeyGracePeriod()
+	live := k.retired[:0]
+	for _, r := range k.retired {
+		if now.Before(r.retiredAt.Add(grace)) {
+			live = append(live, r)
+		}
+	}
+	k.retired = live
+}
+
+// Retire adds an already retired key, e.g. one loaded from storage.
+func (k *Keyring) Retire(key *SigningKey, retiredAt time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.retired = append(k.retired, retiredKey{key: key, retiredAt: retiredAt})
+}
+
+// replace swaps the keyring contents with those of other.
+func (k *Keyring) replace(other *Keyring) {
+	other.mu.RLock()
+	active, retired := other.active, append([]retiredKey(nil), other.retired...)
+	other.mu.RUnlock()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active, k.retired = active, retired
+}
+
+// KeyGracePeriod is how long a retired key keeps verifying tokens. It defaults
+// to the access token lifetime, so every token signed before a rotation can
+// live out its normal life; JWT_KEY_GRACE_PERIOD overrides it.
+func KeyGracePeriod() time.Duration {
+	if grace, err := time.ParseDuration(os.Getenv("JWT_KEY_GRACE_PERIOD")); err == nil && grace > 0 {
+		return grace
+	}
+	return AccessTokenTTL()
+}
+
+var (
+	keyringMu sync.RWMutex
+	keyring   *Keyring
+)
+
+// SetKeyring installs the keyring used by GenerateToken and ValidateToken.
+// Without one, a single-key keyring is built from the environment on each
+// call (see getSigningKey).
+func SetKeyring(k *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = k
+}
+
+func currentKeyring() (*Keyring, error) {
+	keyringMu.RLock()
+	k := keyring
+	keyringMu.RUnlock()
+	if k != nil {
+		return k, nil
+	}
+	key, err := getSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(key), nil
+}
+
+// GenerateKeyMaterial creates fresh key material for alg in the form accepted
+// by NewSigningKey: a random secret for HS256, otherwise a PKCS#8 PEM key.
+func GenerateKeyMaterial(alg string) ([]byte, error) {
+	var priv interface{}
+	var err error
+	switch alg {
+	case "HS256":
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	case "RS256":
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EdDSA":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// KeySyncer brings a keyring up to date with shared key storage, rotating the
+// active key when it is due.
+type KeySyncer interface {
+	Sync(ring *Keyring) error
+}
+
+// StartKeyRotation calls syncer.Sync every interval until the returned stop
+// function is called.
+func StartKeyRotation(syncer KeySyncer, ring *Keyring, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := syncer.Sync(ring); err != nil {
+					log.Printf("Error syncing signing keys: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,78 @@
+package jwt_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T, alg string) *jwt.SigningKey {
+	material, err := jwt.GenerateKeyMaterial(alg)
+	require.NoError(t, err)
+	key, err := jwt.NewSigningKey(alg, material)
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeyringRotationGracePeriod(t *testing.T) {
+	os.Setenv("JWT_EXPIRE_HOURS", "1")
+	os.Setenv("JWT_KEY_GRACE_PERIOD", "1h")
+	defer os.Unsetenv("JWT_KEY_GRACE_PERIOD")
+
+	first := newKey(t, "ES256")
+	second := newKey(t, "ES256")
+	ring := jwt.NewKeyring(first)
+
+	now := time.Now()
+	ring.Rotate(second, now)
+	assert.Equal(t, second.ID, ring.Active().ID)
+
+	_, ok := ring.Lookup(first.ID, now.Add(30*time.Minute))
+	assert.True(t, ok, "retired key should verify during the grace period")
+	_, ok = ring.Lookup(first.ID, now.Add(2*time.Hour))
+	assert.False(t, ok, "retired key should be dropped after the grace period")
+	assert.Len(t, ring.VerificationKeys(now), 2)
+}
+
+func TestTokensCarryKidAndSurviveRotation(t *testing.T) {
+	os.Setenv("JWT_EXPIRE_HOURS", "1")
+	ring := jwt.NewKeyring(newKey(t, "EdDSA"))
+	jwt.SetKeyring(ring)
+	defer jwt.SetKeyring(nil)
+
+	oldToken, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	ring.Rotate(newKey(t, "EdDSA"), time.Now())
+	newToken, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	_, _, err = jwt.ValidateToken(oldToken)
+	assert.NoError(t, err)
+	_, _, err = jwt.ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	set, err := jwt.PublicJWKS()
+	require.NoError(t, err)
+	assert.Len(t, set.Keys, 2)
+
+	// Once the old key has left the keyring its tokens are rejected.
+	jwt.SetKeyring(jwt.NewKeyring(ring.Active()))
+	_, _, err = jwt.ValidateToken(oldToken)
+	assert.Error(t, err)
+}
+
+func TestGenerateKeyMaterial(t *testing.T) {
+	for _, alg := range []string{"HS256", "RS256", "ES256", "EdDSA"} {
+		key := newKey(t, alg)
+		assert.Equal(t, alg, key.Method.Alg())
+		assert.NotEmpty(t, key.ID)
+	}
+	_, err := jwt.GenerateKeyMaterial("none")
+	assert.Error(t, err)
+}
@@ -0,0 +1,339 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is a key this service signs tokens with. For HS256 the secret is
+// used for both signing and verification and is never published; for the
+// asymmetric algorithms only the public half leaves the service.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+	Secret  []byte
+}
+
+// signKey returns the key material passed to jwt.Token.SignedString.
+func (k *SigningKey) signKey() interface{} {
+	if k.Secret != nil {
+		return k.Secret
+	}
+	return k.Private
+}
+
+// verifyKey returns the key material returned from a jwt.Keyfunc.
+func (k *SigningKey) verifyKey() interface{} {
+	if k.Secret != nil {
+		return k.Secret
+	}
+	return k.Public
+}
+
+// JWK is a JSON Web Key (RFC 7517) holding a public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served from /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK describes the key's public half as a JWK. Symmetric keys have no
+// public form and return false.
+func (k *SigningKey) PublicJWK() (JWK, bool) {
+	if k.Secret != nil {
+		return JWK{}, false
+	}
+	jwk, err := PublicKeyToJWK(k.Public)
+	if err != nil {
+		return JWK{}, false
+	}
+	jwk.Use = "sig"
+	jwk.Alg = k.Method.Alg()
+	jwk.Kid = k.ID
+	return jwk, true
+}
+
+// PublicKeyToJWK converts an RSA, ECDSA or Ed25519 public key into a JWK
+// without use, alg or kid set.
+func PublicKeyToJWK(pub crypto.PublicKey) (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   b64(key.X.FillBytes(make([]byte, size))),
+			Y:   b64(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: b64(key)}, nil
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// JWKToPublicKey is the inverse of PublicKeyToJWK.
+func JWKToPublicKey(jwk JWK) (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "RSA":
+		n, err := dec(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := dec(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve %s", jwk.Crv)
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := dec(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of a JWK, base64url
+// encoded. Only the required members of each key type take part.
+func Thumbprint(jwk JWK) (string, error) {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewSigningKey builds a SigningKey for alg from PEM-encoded private key
+// material (or the raw secret for HS256). The key ID is the RFC 7638
+// thumbprint of the public key, so it is stable across restarts.
+func NewSigningKey(alg string, material []byte) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if alg == "HS256" {
+		if len(material) == 0 {
+			return nil, fmt.Errorf("HS256 requires a non-empty secret")
+		}
+		sum := sha256.Sum256(material)
+		return &SigningKey{
+			ID:     base64.RawURLEncoding.EncodeToString(sum[:8]),
+			Method: method,
+			Secret: material,
+		}, nil
+	}
+
+	priv, err := parsePrivateKeyPEM(material)
+	if err != nil {
+		return nil, err
+	}
+	key := &SigningKey{Method: method, Private: priv}
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		if alg != "RS256" {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", alg)
+		}
+		key.Public = &p.PublicKey
+	case *ecdsa.PrivateKey:
+		if alg != "ES256" || p.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ECDSA key cannot be used with %s", alg)
+		}
+		key.Public = &p.PublicKey
+	case ed25519.PrivateKey:
+		if alg != "EdDSA" {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", alg)
+		}
+		key.Public = p.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+
+	jwk, err := PublicKeyToJWK(key.Public)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID, err = Thumbprint(jwk); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func parsePrivateKeyPEM(material []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(material)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unable to parse private key")
+}
+
+var (
+	envKeyMu    sync.Mutex
+	envKeyCache string
+	envKey      *SigningKey
+)
+
+// EnvSigningKey returns the signing key configured by the environment (see
+// getSigningKey).
+func EnvSigningKey() (*SigningKey, error) {
+	return getSigningKey()
+}
+
+// getSigningKey returns the signing key configured by the environment.
+// JWT_SIGNING_ALG selects HS256 (default), RS256, ES256 or EdDSA. HS256 uses
+// JWT_SECRET; the asymmetric algorithms read a PEM private key from
+// JWT_PRIVATE_KEY or the file named by JWT_PRIVATE_KEY_FILE.
+func getSigningKey() (*SigningKey, error) {
+	alg := os.Getenv("JWT_SIGNING_ALG")
+	if alg == "" {
+		alg = "HS256"
+	}
+
+	var material []byte
+	if alg == "HS256" {
+		secret, err := getJwtSecret()
+		if err != nil {
+			return nil, err
+		}
+		material = secret
+	} else if pemKey := os.Getenv("JWT_PRIVATE_KEY"); pemKey != "" {
+		material = []byte(pemKey)
+	} else if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading JWT_PRIVATE_KEY_FILE: %w", err)
+		}
+		material = b
+	} else {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE must be set for %s", alg)
+	}
+
+	envKeyMu.Lock()
+	defer envKeyMu.Unlock()
+	cacheKey := alg + "\x00" + string(material)
+	if envKey != nil && envKeyCache == cacheKey {
+		return envKey, nil
+	}
+	key, err := NewSigningKey(alg, material)
+	if err != nil {
+		return nil, err
+	}
+	envKey, envKeyCache = key, cacheKey
+	return key, nil
+}
+
+// PublicJWKS returns the public keys that tokens issued by this service can
+// be verified with: the active key and any retired key still in its grace
+// period. It is empty when tokens are signed with HS256.
+func PublicJWKS() (JWKSet, error) {
+	set := JWKSet{Keys: []JWK{}}
+	ring, err := currentKeyring()
+	if err != nil {
+		return set, err
+	}
+	for _, key := range ring.VerificationKeys(time.Now()) {
+		if jwk, ok := key.PublicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set, nil
+}
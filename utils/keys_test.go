@@ -0,0 +1,113 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pemEncode(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	cases := []struct {
+		alg string
+		kty string
+		key interface{}
+	}{
+		{"RS256", "RSA", rsaKey},
+		{"ES256", "EC", ecKey},
+		{"EdDSA", "OKP", edKey},
+	}
+	defer os.Unsetenv("JWT_SIGNING_ALG")
+	defer os.Unsetenv("JWT_PRIVATE_KEY")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+
+	for _, tc := range cases {
+		t.Run(tc.alg, func(t *testing.T) {
+			os.Setenv("JWT_SIGNING_ALG", tc.alg)
+			os.Setenv("JWT_PRIVATE_KEY", pemEncode(t, tc.key))
+
+			token, err := jwt.GenerateToken("testuser", "employer")
+			require.NoError(t, err)
+
+			claims, expired, err := jwt.ValidateToken(token)
+			require.NoError(t, err)
+			assert.False(t, expired)
+			assert.Equal(t, "testuser", claims.Username)
+
+			set, err := jwt.PublicJWKS()
+			require.NoError(t, err)
+			require.Len(t, set.Keys, 1)
+			assert.Equal(t, tc.kty, set.Keys[0].Kty)
+			assert.Equal(t, tc.alg, set.Keys[0].Alg)
+			assert.NotEmpty(t, set.Keys[0].Kid)
+
+			pub, err := jwt.JWKToPublicKey(set.Keys[0])
+			require.NoError(t, err)
+			assert.NotNil(t, pub)
+		})
+	}
+}
+
+func TestHS256TokenRejectedWhenAsymmetricConfigured(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	os.Unsetenv("JWT_SIGNING_ALG")
+	hsToken, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	os.Setenv("JWT_SIGNING_ALG", "ES256")
+	os.Setenv("JWT_PRIVATE_KEY", pemEncode(t, ecKey))
+	defer os.Unsetenv("JWT_SIGNING_ALG")
+	defer os.Unsetenv("JWT_PRIVATE_KEY")
+
+	_, _, err = jwt.ValidateToken(hsToken)
+	assert.Error(t, err)
+}
+
+func TestHS256KeysAreNotPublished(t *testing.T) {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Unsetenv("JWT_SIGNING_ALG")
+
+	set, err := jwt.PublicJWKS()
+	assert.NoError(t, err)
+	assert.Empty(t, set.Keys)
+}
+
+func TestThumbprint(t *testing.T) {
+	// Example from RFC 7638, section 3.1.
+	key := jwt.JWK{
+		Kty: "RSA",
+		E:   "AQAB",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91" +
+			"CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+	thumbprint, err := jwt.Thumbprint(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+}
@@ -0,0 +1,167 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SQLKeyStore keeps signing keys in the signing_keys table so that every
+// instance signs with the same active key and rotation needs no deploy. Key
+// material is encrypted at rest with AES-GCM.
+type SQLKeyStore struct {
+	DB          *sql.DB
+	Alg         string
+	RotateEvery time.Duration
+	aead        cipher.AEAD
+	extra       []retiredKey
+}
+
+// NewSQLKeyStore returns a key store that rotates keys for alg every
+// rotateEvery. encryptionKey protects the stored private keys.
+func NewSQLKeyStore(db *sql.DB, alg string, rotateEvery time.Duration, encryptionKey string) (*SQLKeyStore, error) {
+	if encryptionKey == "" {
+		return nil, fmt.Errorf("an encryption key is required to store signing keys")
+	}
+	sum := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLKeyStore{DB: db, Alg: alg, RotateEvery: rotateEvery, aead: aead}, nil
+}
+
+func (s *SQLKeyStore) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (s *SQLKeyStore) open(sealed []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, fmt.Errorf("sealed key is too short")
+	}
+	return s.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// KeepVerifying adds key to every keyring Sync loads as a key retired at
+// retiredAt, so tokens it signed keep verifying for KeyGracePeriod. It is
+// meant for the environment key that signed tokens before rotation was
+// enabled, which is never stored in signing_keys.
+func (s *SQLKeyStore) KeepVerifying(key *SigningKey, retiredAt time.Time) {
+	s.extra = append(s.extra, retiredKey{key: key, retiredAt: retiredAt})
+}
+
+// Sync rotates the active key if it is older than RotateEvery, drops keys
+// whose grace period has passed, and loads the result into ring. An advisory
+// lock ensures that only one instance rotates at a time.
+func (s *SQLKeyStore) Sync(ring *Keyring) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('signing_keys'))"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var activeCreated sql.NullTime
+	if err := tx.QueryRow("SELECT MAX(created_at) FROM signing_keys WHERE retired_at IS NULL").Scan(&activeCreated); err != nil {
+		return err
+	}
+	if !activeCreated.Valid || (s.RotateEvery > 0 && now.Sub(activeCreated.Time) >= s.RotateEvery) {
+		if err := s.rotate(tx, now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM signing_keys WHERE retired_at IS NOT NULL AND retired_at < $1", now.Add(-KeyGracePeriod())); err != nil {
+		return err
+	}
+
+	loaded, err := s.load(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	ring.replace(loaded)
+	return nil
+}
+
+// rotate retires the current active key and inserts a freshly generated one.
+func (s *SQLKeyStore) rotate(tx *sql.Tx, now time.Time) error {
+	material, err := GenerateKeyMaterial(s.Alg)
+	if err != nil {
+		return err
+	}
+	key, err := NewSigningKey(s.Alg, material)
+	if err != nil {
+		return err
+	}
+	sealed, err := s.seal(material)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE signing_keys SET retired_at = $1 WHERE retired_at IS NULL", now); err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO signing_keys (kid, alg, material, created_at) VALUES ($1, $2, $3, $4)",
+		key.ID, s.Alg, sealed, now)
+	return err
+}
+
+func (s *SQLKeyStore) load(tx *sql.Tx) (*Keyring, error) {
+	rows, err := tx.Query("SELECT alg, material, retired_at FROM signing_keys ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ring := &Keyring{}
+	for rows.Next() {
+		var alg string
+		var sealed []byte
+		var retiredAt sql.NullTime
+		if err := rows.Scan(&alg, &sealed, &retiredAt); err != nil {
+			return nil, err
+		}
+		material, err := s.open(sealed)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting signing key: %w", err)
+		}
+		key, err := NewSigningKey(alg, material)
+		if err != nil {
+			return nil, err
+		}
+		if retiredAt.Valid {
+			ring.Retire(key, retiredAt.Time)
+		} else {
+			ring.active = key
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, r := range s.extra {
+		ring.Retire(r.key, r.retiredAt)
+	}
+	if ring.active == nil {
+		return nil, fmt.Errorf("no active signing key")
+	}
+	return ring, nil
+}
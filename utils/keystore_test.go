@@ -0,0 +1,134 @@
+package jwt_test
+
+import (
+	"database/sql/driver"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capture matches any argument and remembers it.
+type capture struct{ value *driver.Value }
+
+func (c capture) Match(v driver.Value) bool {
+	*c.value = v
+	return true
+}
+
+// expectKeySync expects the statements Sync runs up to the rotation check.
+func expectKeySync(mock sqlmock.Sqlmock, activeCreated interface{}) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT MAX\\(created_at\\) FROM signing_keys WHERE retired_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(activeCreated))
+}
+
+func expectKeyCleanup(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("DELETE FROM signing_keys WHERE retired_at IS NOT NULL AND retired_at < \\$1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// rotateKey syncs store against an empty signing_keys table and returns the
+// kid and encrypted material of the key it generated. Loading is made to
+// fail, since the stored row is only known once the insert has run.
+func rotateKey(t *testing.T, store *jwt.SQLKeyStore, mock sqlmock.Sqlmock) (string, []byte) {
+	var kid, sealed driver.Value
+	expectKeySync(mock, nil)
+	mock.ExpectExec("UPDATE signing_keys SET retired_at = \\$1 WHERE retired_at IS NULL").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO signing_keys").
+		WithArgs(capture{&kid}, "ES256", capture{&sealed}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectKeyCleanup(mock)
+	loadFailed := errors.New("load skipped")
+	mock.ExpectQuery("SELECT alg, material, retired_at FROM signing_keys").WillReturnError(loadFailed)
+	mock.ExpectRollback()
+
+	require.ErrorIs(t, store.Sync(&jwt.Keyring{}), loadFailed)
+	return kid.(string), sealed.([]byte)
+}
+
+func newKeyStore(t *testing.T, encryptionKey string) (*jwt.SQLKeyStore, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+	store, err := jwt.NewSQLKeyStore(mockDB, "ES256", 24*time.Hour, encryptionKey)
+	require.NoError(t, err)
+	return store, mock
+}
+
+// expectKeyLoad expects a sync that finds a fresh active key and loads rows.
+func expectKeyLoad(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	expectKeySync(mock, time.Now())
+	expectKeyCleanup(mock)
+	mock.ExpectQuery("SELECT alg, material, retired_at FROM signing_keys").WillReturnRows(rows)
+}
+
+func TestSQLKeyStoreRotateAndLoad(t *testing.T) {
+	os.Setenv("JWT_EXPIRE_HOURS", "1")
+	store, mock := newKeyStore(t, "encryption-key")
+
+	oldKid, oldSealed := rotateKey(t, store, mock)
+	kid, sealed := rotateKey(t, store, mock)
+	assert.NotContains(t, string(sealed), "PRIVATE KEY", "key material must be stored encrypted")
+
+	now := time.Now()
+	expectKeyLoad(mock, sqlmock.NewRows([]string{"alg", "material", "retired_at"}).
+		AddRow("ES256", oldSealed, now.Add(-10*time.Minute)).
+		AddRow("ES256", sealed, nil))
+	mock.ExpectCommit()
+
+	ring := &jwt.Keyring{}
+	require.NoError(t, store.Sync(ring))
+	require.NotNil(t, ring.Active())
+	assert.Equal(t, kid, ring.Active().ID)
+	_, ok := ring.Lookup(oldKid, now)
+	assert.True(t, ok, "retired key should still verify")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLKeyStoreWrongEncryptionKey(t *testing.T) {
+	store, mock := newKeyStore(t, "encryption-key")
+	_, sealed := rotateKey(t, store, mock)
+
+	other, mock := newKeyStore(t, "another-key")
+	expectKeyLoad(mock, sqlmock.NewRows([]string{"alg", "material", "retired_at"}).AddRow("ES256", sealed, nil))
+	mock.ExpectRollback()
+
+	err := other.Sync(&jwt.Keyring{})
+	assert.ErrorContains(t, err, "decrypting signing key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// The environment key used before rotation was enabled keeps verifying
+// across syncs until its grace period is over.
+func TestSQLKeyStoreKeepVerifying(t *testing.T) {
+	os.Setenv("JWT_EXPIRE_HOURS", "1")
+	store, mock := newKeyStore(t, "encryption-key")
+	_, sealed := rotateKey(t, store, mock)
+
+	envKey := newKey(t, "ES256")
+	now := time.Now()
+	store.KeepVerifying(envKey, now)
+
+	ring := &jwt.Keyring{}
+	for i := 0; i < 2; i++ {
+		expectKeyLoad(mock, sqlmock.NewRows([]string{"alg", "material", "retired_at"}).AddRow("ES256", sealed, nil))
+		mock.ExpectCommit()
+		require.NoError(t, store.Sync(ring))
+	}
+
+	_, ok := ring.Lookup(envKey.ID, now.Add(30*time.Minute))
+	assert.True(t, ok, "environment key should verify during the grace period")
+	_, ok = ring.Lookup(envKey.ID, now.Add(2*time.Hour))
+	assert.False(t, ok, "environment key should be dropped after the grace period")
+	assert.Len(t, ring.VerificationKeys(now), 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
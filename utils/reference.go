@@ -0,0 +1,241 @@
+package jwt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReferenceTokenNotFound is returned for opaque tokens with no stored record.
+var ErrReferenceTokenNotFound = errors.New("reference token not found")
+
+// ReferenceTokensEnabled reports whether access tokens are issued as opaque
+// reference tokens (ACCESS_TOKEN_FORMAT=reference) instead of JWTs.
+func ReferenceTokensEnabled() bool {
+	return os.Getenv("ACCESS_TOKEN_FORMAT") == "reference"
+}
+
+// ReferenceTokenStore keeps the claims behind opaque reference tokens, keyed
+// by the SHA-256 hash of the token.
+type ReferenceTokenStore interface {
+	Save(hash string, claims *Claims) error
+	Load(hash string) (*Claims, error)
+	Purge(now time.Time) error
+}
+
+var (
+	referenceStoreMu sync.RWMutex
+	referenceStore   ReferenceTokenStore = NewMemoryReferenceStore()
+)
+
+// SetReferenceTokenStore replaces the store used for reference tokens. The
+// default is an in-memory store, which is only correct for a single instance.
+func SetReferenceTokenStore(s ReferenceTokenStore) {
+	referenceStoreMu.Lock()
+	defer referenceStoreMu.Unlock()
+	referenceStore = s
+}
+
+func currentReferenceStore() ReferenceTokenStore {
+	referenceStoreMu.RLock()
+	defer referenceStoreMu.RUnlock()
+	return referenceStore
+}
+
+// IssueReferenceToken stores claims server-side and returns a random opaque
+// token that refers to them.
+func IssueReferenceToken(claims *Claims, ttl time.Duration) (string, error) {
+	if err := stampClaims(claims, ttl); err != nil {
+		return "", err
+	}
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := currentReferenceStore().Save(HashOpaqueToken(token), claims); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// StartReferenceTokenSweeper deletes expired reference tokens every interval.
+func StartReferenceTokenSweeper(interval time.Duration) (stop func()) {
+	return StartSweeper("reference tokens", interval, func(now time.Time) error {
+		return currentReferenceStore().Purge(now)
+	})
+}
+
+// isJWT distinguishes compact JWTs (three dot-separated segments) from opaque
+// reference tokens, which are base64url and never contain a dot.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func validateReferenceToken(token string, opts ValidationOptions) (*Claims, bool, error) {
+	claims, err := currentReferenceStore().Load(HashOpaqueToken(token))
+	if errors.Is(err, ErrReferenceTokenNotFound) {
+		return nil, false, fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return checkClaims(claims, opts, time.Now())
+}
+
+// MemoryReferenceStore is a process-local ReferenceTokenStore.
+type MemoryReferenceStore struct {
+	mu     sync.RWMutex
+	tokens map[string]*Claims
+}
+
+// NewMemoryReferenceStore returns an empty in-memory store.
+func NewMemoryReferenceStore() *MemoryReferenceStore {
+	return &MemoryReferenceStore{tokens: make(map[string]*Claims)}
+}
+
+func (m *MemoryReferenceStore) Save(hash string, claims *Claims) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[hash] = claims
+	return nil
+}
+
+func (m *MemoryReferenceStore) Load(hash string) (*Claims, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	claims, ok := m.tokens[hash]
+	if !ok {
+		return nil, ErrReferenceTokenNotFound
+	}
+	copied := *claims
+	return &copied, nil
+}
+
+func (m *MemoryReferenceStore) Purge(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, claims := range m.tokens {
+		if claims.ExpiresAt != nil && !claims.ExpiresAt.After(now) {
+			delete(m.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// SQLReferenceStore keeps reference tokens in the reference_tokens table.
+type SQLReferenceStore struct {
+	DB *sql.DB
+}
+
+// NewSQLReferenceStore returns a ReferenceTokenStore backed by db.
+func NewSQLReferenceStore(db *sql.DB) *SQLReferenceStore {
+	return &SQLReferenceStore{DB: db}
+}
+
+func (s *SQLReferenceStore) Save(hash string, claims *Claims) error {
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec(`INSERT INTO reference_tokens (token_hash, username, claims, expires_at)
+		VALUES ($1, $2, $3, $4)`, hash, claims.Subject, body, claims.ExpiresAt.Time)
+	return err
+}
+
+func (s *SQLReferenceStore) Load(hash string) (*Claims, error) {
+	var body []byte
+	err := s.DB.QueryRow("SELECT claims FROM reference_tokens WHERE token_hash = $1", hash).Scan(&body)
+	if err == sql.ErrNoRows {
+		return nil, ErrReferenceTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(body, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *SQLReferenceStore) Purge(now time.Time) error {
+	_, err := s.DB.Exec("DELETE FROM reference_tokens WHERE expires_at <= $1", now)
+	return err
+}
+
+// CachedReferenceStore keeps recently loaded claims in memory for ttl so that
+// each request does not hit the backing store. Revocation stays immediate
+// because the denylist and token version are checked after the lookup.
+type CachedReferenceStore struct {
+	Store ReferenceTokenStore
+	TTL   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedClaims
+}
+
+type cachedClaims struct {
+	claims    *Claims
+	fetchedAt time.Time
+}
+
+// maxCachedReferenceTokens bounds the cache; expired entries are dropped when
+// it fills up, and if that is not enough the cache is cleared.
+const maxCachedReferenceTokens = 10000
+
+// NewCachedReferenceStore wraps store with an in-memory cache.
+func NewCachedReferenceStore(store ReferenceTokenStore, ttl time.Duration) *CachedReferenceStore {
+	return &CachedReferenceStore{Store: store, TTL: ttl, entries: make(map[string]cachedClaims)}
+}
+
+func (c *CachedReferenceStore) Save(hash string, claims *Claims) error {
+	return c.Store.Save(hash, claims)
+}
+
+func (c *CachedReferenceStore) Load(hash string) (*Claims, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if entry, ok := c.entries[hash]; ok && now.Sub(entry.fetchedAt) < c.TTL {
+		c.mu.Unlock()
+		copied := *entry.claims
+		return &copied, nil
+	}
+	c.mu.Unlock()
+
+	claims, err := c.Store.Load(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedReferenceTokens {
+		for h, entry := range c.entries {
+			if now.Sub(entry.fetchedAt) >= c.TTL {
+				delete(c.entries, h)
+			}
+		}
+		if len(c.entries) >= maxCachedReferenceTokens {
+			c.entries = make(map[string]cachedClaims)
+		}
+	}
+	c.entries[hash] = cachedClaims{claims: claims, fetchedAt: now}
+	copied := *claims
+	return &copied, nil
+}
+
+func (c *CachedReferenceStore) Purge(now time.Time) error {
+	c.mu.Lock()
+	for h, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= c.TTL {
+			delete(c.entries, h)
+		}
+	}
+	c.mu.Unlock()
+	return c.Store.Purge(now)
+}
@@ -0,0 +1,83 @@
+package jwt_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceTokens(t *testing.T) {
+	setValidationEnv()
+	os.Setenv("ACCESS_TOKEN_FORMAT", "reference")
+	defer os.Unsetenv("ACCESS_TOKEN_FORMAT")
+	jwt.SetReferenceTokenStore(jwt.NewMemoryReferenceStore())
+	jwt.SetDenylist(jwt.NewMemoryDenylist())
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(token, "."), "reference tokens are opaque")
+
+	claims, expired, err := jwt.ValidateToken(token)
+	require.NoError(t, err)
+	assert.False(t, expired)
+	assert.Equal(t, "testuser", claims.Username)
+	assert.Equal(t, "employer", claims.Role)
+
+	require.NoError(t, jwt.RevokeToken(claims))
+	_, _, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenRevoked)
+
+	_, _, err = jwt.ValidateToken("unknownopaquetoken")
+	assert.ErrorIs(t, err, jwt.ErrTokenMalformed)
+}
+
+func TestReferenceTokenExpiry(t *testing.T) {
+	setValidationEnv()
+	os.Setenv("JWT_LEEWAY", "0s")
+	defer os.Unsetenv("JWT_LEEWAY")
+	jwt.SetReferenceTokenStore(jwt.NewMemoryReferenceStore())
+
+	claims := &jwt.Claims{Username: "testuser"}
+	claims.Subject = "testuser"
+	token, err := jwt.IssueReferenceToken(claims, -time.Minute)
+	require.NoError(t, err)
+
+	_, expired, err := jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	assert.True(t, expired)
+}
+
+// countingStore counts loads that reach the backing store.
+type countingStore struct {
+	*jwt.MemoryReferenceStore
+	loads int
+}
+
+func (c *countingStore) Load(hash string) (*jwt.Claims, error) {
+	c.loads++
+	return c.MemoryReferenceStore.Load(hash)
+}
+
+func TestCachedReferenceStore(t *testing.T) {
+	setValidationEnv()
+	backing := &countingStore{MemoryReferenceStore: jwt.NewMemoryReferenceStore()}
+	jwt.SetReferenceTokenStore(jwt.NewCachedReferenceStore(backing, time.Minute))
+	defer jwt.SetReferenceTokenStore(jwt.NewMemoryReferenceStore())
+
+	claims := &jwt.Claims{Username: "testuser"}
+	claims.Subject = "testuser"
+	token, err := jwt.IssueReferenceToken(claims, time.Hour)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, _, err := jwt.ValidateToken(token)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, backing.loads)
+}
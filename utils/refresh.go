@@ -0,0 +1,37 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// GenerateOpaqueToken returns a random URL-safe string carrying 256 bits of
+// entropy, suitable for refresh tokens and other bearer secrets.
+func GenerateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashOpaqueToken returns the hex-encoded SHA-256 digest of an opaque token.
+// Only this digest is persisted, so a database leak does not expose usable
+// tokens.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RefreshTokenTTL returns the lifetime of refresh tokens, read from
+// JWT_REFRESH_TTL as a Go duration. It defaults to 30 days.
+func RefreshTokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_REFRESH_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 30 * 24 * time.Hour
+}
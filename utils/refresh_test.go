@@ -0,0 +1,39 @@
+package jwt_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateOpaqueToken(t *testing.T) {
+	a, err := jwt.GenerateOpaqueToken()
+	assert.NoError(t, err)
+	b, err := jwt.GenerateOpaqueToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.Len(t, a, 43)
+}
+
+func TestHashOpaqueToken(t *testing.T) {
+	assert.Equal(t, jwt.HashOpaqueToken("abc"), jwt.HashOpaqueToken("abc"))
+	assert.NotEqual(t, jwt.HashOpaqueToken("abc"), jwt.HashOpaqueToken("abd"))
+	assert.Len(t, jwt.HashOpaqueToken("abc"), 64)
+}
+
+func TestAccessTokenTTL(t *testing.T) {
+	os.Unsetenv("JWT_EXPIRE_HOURS")
+	os.Unsetenv("JWT_ACCESS_TTL")
+	assert.Equal(t, 15*time.Minute, jwt.AccessTokenTTL())
+
+	os.Setenv("JWT_EXPIRE_HOURS", "2")
+	assert.Equal(t, 2*time.Hour, jwt.AccessTokenTTL())
+
+	os.Setenv("JWT_ACCESS_TTL", "5m")
+	assert.Equal(t, 5*time.Minute, jwt.AccessTokenTTL())
+	os.Unsetenv("JWT_ACCESS_TTL")
+}
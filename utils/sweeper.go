@@ -0,0 +1,28 @@
+package jwt
+
+import (
+	"log"
+	"time"
+)
+
+// StartSweeper runs sweep every interval in the background until the returned
+// stop function is called. Errors are logged under name and do not stop the
+// sweeper.
+func StartSweeper(name string, interval time.Duration, sweep func(now time.Time) error) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := sweep(time.Now()); err != nil {
+					log.Printf("Error sweeping %s: %v", name, err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
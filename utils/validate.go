@@ -0,0 +1,244 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Errors returned by ValidateToken, one per reason a token can be rejected.
+// They may be wrapped, so compare with errors.Is.
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrUnsupportedAlgorithm  = errors.New("token signing algorithm is not allowed")
+	ErrUnknownSigningKey     = errors.New("token signing key is unknown")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotYetValid      = errors.New("token is not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	ErrInvalidIssuer         = errors.New("token issuer is invalid")
+	ErrInvalidAudience       = errors.New("token audience is invalid")
+	ErrMissingClaim          = errors.New("token is missing a required claim")
+	ErrTokenVersionStale     = errors.New("token was issued before the user's sessions were revoked")
+	ErrUnknownSubject        = errors.New("token subject no longer exists")
+)
+
+// ValidationOptions controls which tokens ValidateTokenWithOptions accepts.
+type ValidationOptions struct {
+	// Issuer, if set, must equal the iss claim.
+	Issuer string
+	// Audiences, if set, must share at least one value with the aud claim.
+	Audiences []string
+	// Algorithms lists the acceptable alg header values. When empty, the
+	// algorithms of the keys in the keyring are allowed.
+	Algorithms []string
+	// Leeway tolerates clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+	// RequiredClaims names claims that must be present: any of "sub",
+	// "jti", "exp" and "iat".
+	RequiredClaims []string
+}
+
+// DefaultValidationOptions builds options from the environment: JWT_ISSUER,
+// JWT_AUDIENCE, JWT_ALLOWED_ALGS (comma-separated) and JWT_LEEWAY (a Go
+// duration, 30s by default).
+func DefaultValidationOptions() ValidationOptions {
+	opts := ValidationOptions{
+		Issuer:         os.Getenv("JWT_ISSUER"),
+		Algorithms:     splitList(os.Getenv("JWT_ALLOWED_ALGS")),
+		Leeway:         30 * time.Second,
+		RequiredClaims: []string{"sub", "jti", "exp", "iat"},
+	}
+	if aud := DefaultAudience(); aud != "" {
+		opts.Audiences = []string{aud}
+	}
+	if leeway, err := time.ParseDuration(os.Getenv("JWT_LEEWAY")); err == nil && leeway >= 0 {
+		opts.Leeway = leeway
+	}
+	return opts
+}
+
+// ValidateToken validates a token and returns its claims, a boolean indicating
+// expiration, and an error if any. The token must be addressed to one of the
+// given audiences, or to the default audience when none are given.
+func ValidateToken(tokenStr string, audiences ...string) (*Claims, bool, error) {
+	opts := DefaultValidationOptions()
+	if len(audiences) > 0 {
+		opts.Audiences = audiences
+	}
+	return ValidateTokenWithOptions(tokenStr, opts)
+}
+
+// ValidateTokenWithOptions is ValidateToken with explicit options. Expired
+// tokens return their claims together with expired=true and ErrTokenExpired.
+// Both JWTs and opaque reference tokens are accepted.
+func ValidateTokenWithOptions(tokenStr string, opts ValidationOptions) (*Claims, bool, error) {
+	if !isJWT(tokenStr) {
+		return validateReferenceToken(tokenStr, opts)
+	}
+
+	ring, err := currentKeyring()
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+
+	allowed := opts.Algorithms
+	if len(allowed) == 0 {
+		for _, key := range ring.VerificationKeys(now) {
+			allowed = append(allowed, key.Method.Alg())
+		}
+	}
+
+	// Time-based claims are checked below so that leeway can be applied.
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	claims := &Claims{}
+	_, err = parser.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		alg := token.Method.Alg()
+		if !containsString(allowed, alg) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+		}
+		// Tokens minted before kid headers existed are checked against the
+		// active key.
+		key := ring.Active()
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, ok = ring.Lookup(kid, now); !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownSigningKey, kid)
+			}
+		}
+		// Only accept the algorithm of the matching key, so a public key can
+		// never be misused as an HMAC secret.
+		if alg != key.Method.Alg() {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+		}
+		return key.verifyKey(), nil
+	})
+	if err != nil {
+		return nil, false, parseError(err)
+	}
+
+	return checkClaims(claims, opts, now)
+}
+
+// checkClaims applies the claim, revocation and token version checks shared by
+// every token format once the token itself has been authenticated.
+func checkClaims(claims *Claims, opts ValidationOptions, now time.Time) (*Claims, bool, error) {
+	if err := verifyClaims(claims, opts, now); err != nil {
+		if errors.Is(err, ErrTokenExpired) {
+			return claims, true, err
+		}
+		return nil, false, err
+	}
+
+	// Reject tokens that were revoked before they expired (e.g. by logout).
+	if claims.ID != "" {
+		revoked, err := currentDenylist().IsRevoked(claims.ID)
+		if err != nil {
+			return nil, false, fmt.Errorf("checking token revocation: %w", err)
+		}
+		if revoked {
+			return nil, false, ErrTokenRevoked
+		}
+	}
+
+	// Reject tokens minted before the user's sessions were revoked.
+	if lookup := currentTokenVersionLookup(); lookup != nil {
+		version, err := lookup(claims.Subject)
+		if errors.Is(err, ErrUnknownSubject) {
+			return nil, false, err
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("checking token version: %w", err)
+		}
+		if claims.TokenVersion < version {
+			return nil, false, ErrTokenVersionStale
+		}
+	}
+
+	return claims, false, nil
+}
+
+// parseError maps a jwt-go parse error onto the package's typed errors.
+func parseError(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+	for _, typed := range []error{ErrUnsupportedAlgorithm, ErrUnknownSigningKey} {
+		if errors.Is(ve.Inner, typed) {
+			return ve.Inner
+		}
+	}
+	if ve.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+		return ErrTokenSignatureInvalid
+	}
+	return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+}
+
+// verifyClaims checks required, temporal, issuer and audience claims.
+func verifyClaims(claims *Claims, opts ValidationOptions, now time.Time) error {
+	for _, name := range opts.RequiredClaims {
+		missing := false
+		switch name {
+		case "sub":
+			missing = claims.Subject == ""
+		case "jti":
+			missing = claims.ID == ""
+		case "exp":
+			missing = claims.ExpiresAt == nil
+		case "iat":
+			missing = claims.IssuedAt == nil
+		}
+		if missing {
+			return fmt.Errorf("%w: %s", ErrMissingClaim, name)
+		}
+	}
+
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Add(opts.Leeway)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != nil && now.Add(opts.Leeway).Before(claims.NotBefore.Time) {
+		return ErrTokenNotYetValid
+	}
+	if claims.IssuedAt != nil && now.Add(opts.Leeway).Before(claims.IssuedAt.Time) {
+		return ErrTokenUsedBeforeIssued
+	}
+
+	if opts.Issuer != "" {
+		if claims.Issuer == "" {
+			return fmt.Errorf("%w: iss", ErrMissingClaim)
+		}
+		if claims.Issuer != opts.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+
+	if len(opts.Audiences) > 0 {
+		if len(claims.Audience) == 0 {
+			return fmt.Errorf("%w: aud", ErrMissingClaim)
+		}
+		matched := false
+		for _, aud := range claims.Audience {
+			if containsString(opts.Audiences, aud) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return ErrInvalidAudience
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
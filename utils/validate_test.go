@@ -0,0 +1,124 @@
+package jwt_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "auth-service/utils"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setValidationEnv() {
+	os.Setenv("JWT_SECRET", "supersecret")
+	os.Setenv("JWT_EXPIRE_HOURS", "72")
+	os.Setenv("JWT_ISSUER", "test-issuer")
+	os.Unsetenv("JWT_SIGNING_ALG")
+	os.Unsetenv("JWT_AUDIENCE")
+	os.Unsetenv("JWT_LEEWAY")
+	os.Unsetenv("JWT_ALLOWED_ALGS")
+}
+
+func TestValidateTokenAudience(t *testing.T) {
+	setValidationEnv()
+
+	token, err := jwt.GenerateTokenForAudience("testuser", "employer", "jobs-api")
+	require.NoError(t, err)
+
+	claims, _, err := jwt.ValidateToken(token, "jobs-api")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jobs-api"}, []string(claims.Audience))
+
+	_, _, err = jwt.ValidateToken(token, "admin-api")
+	assert.ErrorIs(t, err, jwt.ErrInvalidAudience)
+}
+
+func TestValidateTokenMissingAudience(t *testing.T) {
+	setValidationEnv()
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	_, _, err = jwt.ValidateToken(token, "jobs-api")
+	assert.ErrorIs(t, err, jwt.ErrMissingClaim)
+}
+
+func TestValidateTokenIssuer(t *testing.T) {
+	setValidationEnv()
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	os.Setenv("JWT_ISSUER", "someone-else")
+	_, _, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrInvalidIssuer)
+	os.Setenv("JWT_ISSUER", "test-issuer")
+}
+
+func TestValidateTokenAlgorithmAllowlist(t *testing.T) {
+	setValidationEnv()
+
+	// A token signed with an algorithm the keyring does not use.
+	claims := gojwt.RegisteredClaims{Subject: "testuser", ID: "x",
+		IssuedAt: gojwt.NewNumericDate(time.Now()), ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Hour))}
+	hs512, err := gojwt.NewWithClaims(gojwt.SigningMethodHS512, claims).SignedString([]byte("supersecret"))
+	require.NoError(t, err)
+	_, _, err = jwt.ValidateToken(hs512)
+	assert.ErrorIs(t, err, jwt.ErrUnsupportedAlgorithm)
+
+	unsigned, err := gojwt.NewWithClaims(gojwt.SigningMethodNone, claims).SignedString(gojwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, _, err = jwt.ValidateToken(unsigned)
+	assert.ErrorIs(t, err, jwt.ErrUnsupportedAlgorithm)
+}
+
+func TestValidateTokenBadSignature(t *testing.T) {
+	setValidationEnv()
+
+	token, err := jwt.GenerateToken("testuser", "employer")
+	require.NoError(t, err)
+
+	// Re-sign the same header and payload with another secret, so the kid
+	// still names a known key but the signature does not match it.
+	parsed, parts, err := new(gojwt.Parser).ParseUnverified(token, &gojwt.RegisteredClaims{})
+	require.NoError(t, err)
+	signingString := parts[0] + "." + parts[1]
+	forged, err := parsed.Method.Sign(signingString, []byte("anothersecret"))
+	require.NoError(t, err)
+
+	_, _, err = jwt.ValidateToken(signingString + "." + forged)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+}
+
+func TestValidateTokenRequiredClaims(t *testing.T) {
+	setValidationEnv()
+
+	claims := &jwt.Claims{Username: "testuser"}
+	token, err := jwt.IssueToken(claims, time.Hour)
+	require.NoError(t, err)
+
+	_, _, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrMissingClaim)
+}
+
+func TestValidateTokenLeeway(t *testing.T) {
+	setValidationEnv()
+
+	claims := &jwt.Claims{Username: "testuser"}
+	claims.Subject = "testuser"
+	token, err := jwt.IssueToken(claims, -10*time.Second)
+	require.NoError(t, err)
+
+	_, expired, err := jwt.ValidateToken(token)
+	assert.NoError(t, err, "a token expired within the leeway is accepted")
+	assert.False(t, expired)
+
+	os.Setenv("JWT_LEEWAY", "0s")
+	defer os.Unsetenv("JWT_LEEWAY")
+	_, expired, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	assert.True(t, expired)
+}
@@ -0,0 +1,43 @@
+package jwt
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// TokenVersionLookup returns the current token_version of a user. Tokens
+// carrying an older version are rejected by ValidateToken.
+type TokenVersionLookup func(username string) (int, error)
+
+var (
+	versionLookupMu sync.RWMutex
+	versionLookup   TokenVersionLookup
+)
+
+// SetTokenVersionLookup installs the lookup consulted by ValidateToken. With
+// none installed, token versions are not checked.
+func SetTokenVersionLookup(lookup TokenVersionLookup) {
+	versionLookupMu.Lock()
+	defer versionLookupMu.Unlock()
+	versionLookup = lookup
+}
+
+func currentTokenVersionLookup() TokenVersionLookup {
+	versionLookupMu.RLock()
+	defer versionLookupMu.RUnlock()
+	return versionLookup
+}
+
+// SQLTokenVersionLookup reads token versions from the users table. Tokens for
+// users that no longer exist or were deleted are rejected with
+// ErrUnknownSubject.
+func SQLTokenVersionLookup(db *sql.DB) TokenVersionLookup {
+	return func(username string) (int, error) {
+		var version int
+		err := db.QueryRow("SELECT token_version FROM users WHERE username = $1 AND deleted_at IS NULL", username).Scan(&version)
+		if err == sql.ErrNoRows {
+			return 0, ErrUnknownSubject
+		}
+		return version, err
+	}
+}
@@ -0,0 +1,37 @@
+package jwt_test
+
+import (
+	"testing"
+
+	jwt "auth-service/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaleTokenVersionIsRejected(t *testing.T) {
+	setValidationEnv()
+	versions := map[string]int{"testuser": 3}
+	jwt.SetTokenVersionLookup(func(username string) (int, error) {
+		version, ok := versions[username]
+		if !ok {
+			return 0, jwt.ErrUnknownSubject
+		}
+		return version, nil
+	})
+	defer jwt.SetTokenVersionLookup(nil)
+
+	token, err := jwt.GenerateUserToken(jwt.TokenParams{Username: "testuser", Role: "employer", TokenVersion: 3})
+	require.NoError(t, err)
+	claims, _, err := jwt.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claims.TokenVersion)
+
+	versions["testuser"] = 4
+	_, _, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenVersionStale)
+
+	delete(versions, "testuser")
+	_, _, err = jwt.ValidateToken(token)
+	assert.ErrorIs(t, err, jwt.ErrUnknownSubject)
+}